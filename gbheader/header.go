@@ -0,0 +1,203 @@
+// Package gbheader parses and verifies the cartridge header found at
+// 0x0100 - 0x014f of every GameBoy ROM.
+package gbheader
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+/* Header field offsets */
+const (
+	EntryPoint      = 0x0100 // usually nop; jp 0x0150
+	LogoStart       = 0x0104 // 0x0104 - 0x0133
+	TitleStart      = 0x0134 // 0x0134 - 0x0143, shorter on newer cartridges
+	ManufacturerEnd = 0x0143 // 0x013f - 0x0142 on newer cartridges
+	CGBFlag         = 0x0143
+	NewLicensee     = 0x0144 // 0x0144 - 0x0145, two ASCII characters
+	SGBFlag         = 0x0146
+	CartridgeType   = 0x0147
+	ROMSize         = 0x0148
+	RAMSize         = 0x0149
+	Destination     = 0x014a
+	OldLicensee     = 0x014b
+	MaskROMVersion  = 0x014c
+	HeaderChecksum  = 0x014d
+	GlobalChecksum  = 0x014e // 0x014e - 0x014f, big endian
+	HeaderEnd       = 0x0150
+)
+
+const (
+	CGBSupported = 0x80 // game works on DMG and CGB
+	CGBOnly      = 0xc0
+	SGBSupported = 0x03
+	/* OldLicensee value meaning the new licensee code is used instead */
+	UseNewLicensee = 0x33
+)
+
+/* The boot ROM refuses to start a cartridge unless this bitmap matches */
+var NintendoLogo = [48]uint8{
+	0xce, 0xed, 0x66, 0x66, 0xcc, 0x0d, 0x00, 0x0b, 0x03, 0x73, 0x00, 0x83,
+	0x00, 0x0c, 0x00, 0x0d, 0x00, 0x08, 0x11, 0x1f, 0x88, 0x89, 0x00, 0x0e,
+	0xdc, 0xcc, 0x6e, 0xe6, 0xdd, 0xdd, 0xd9, 0x99, 0xbb, 0xbb, 0x67, 0x63,
+	0x6e, 0x0e, 0xec, 0xcc, 0xdd, 0xdc, 0x99, 0x9f, 0xbb, 0xb9, 0x33, 0x3e,
+}
+
+type Header struct {
+	Logo             [48]uint8
+	Title            string
+	ManufacturerCode string // empty on cartridges that predate it
+	CGBFlag          uint8
+	NewLicenseeCode  string
+	SGBFlag          uint8
+	CartridgeType    uint8
+	ROMSize          uint8
+	RAMSize          uint8
+	Destination      uint8 // 0x00 Japan, 0x01 elsewhere
+	OldLicenseeCode  uint8
+	MaskROMVersion   uint8
+	HeaderChecksum   uint8
+	GlobalChecksum   uint16
+	/* checksums computed over the ROM the header was parsed from */
+	ComputedHeaderChecksum uint8
+	ComputedGlobalChecksum uint16
+}
+
+var ErrTooShort = errors.New("ROM is too short to contain a header")
+
+// Parse extracts the header from a ROM image
+func Parse(rom []uint8) (*Header, error) {
+	if len(rom) < HeaderEnd {
+		return nil, ErrTooShort
+	}
+	h := &Header{
+		CGBFlag:         rom[CGBFlag],
+		NewLicenseeCode: string(rom[NewLicensee : NewLicensee+2]),
+		SGBFlag:         rom[SGBFlag],
+		CartridgeType:   rom[CartridgeType],
+		ROMSize:         rom[ROMSize],
+		RAMSize:         rom[RAMSize],
+		Destination:     rom[Destination],
+		OldLicenseeCode: rom[OldLicensee],
+		MaskROMVersion:  rom[MaskROMVersion],
+		HeaderChecksum:  rom[HeaderChecksum],
+		GlobalChecksum:  binary.BigEndian.Uint16(rom[GlobalChecksum:]),
+	}
+	copy(h.Logo[:], rom[LogoStart:])
+
+	/*
+	 * Originally the title took all 16 bytes. CGB era cartridges use the
+	 * last one for the CGB flag and may use the 4 before it for a
+	 * manufacturer code.
+	 */
+	titleEnd := CGBFlag + 1
+	if h.CGBFlag&CGBSupported != 0 {
+		titleEnd = CGBFlag
+		if code := rom[ManufacturerEnd-4 : ManufacturerEnd]; isManufacturerCode(code) {
+			h.ManufacturerCode = string(code)
+			titleEnd = ManufacturerEnd - 4
+		}
+	}
+	h.Title = strings.TrimRight(string(rom[TitleStart:titleEnd]), "\x00 ")
+
+	h.ComputedHeaderChecksum = ComputeHeaderChecksum(rom)
+	h.ComputedGlobalChecksum = ComputeGlobalChecksum(rom)
+	return h, nil
+}
+
+func isManufacturerCode(code []uint8) bool {
+	for _, c := range code {
+		if !(c >= 'A' && c <= 'Z' || c >= '0' && c <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// ComputeHeaderChecksum sums 0x0134 - 0x014c as the boot ROM does
+func ComputeHeaderChecksum(rom []uint8) uint8 {
+	var x uint8
+	for _, b := range rom[TitleStart:HeaderChecksum] {
+		x = x - b - 1
+	}
+	return x
+}
+
+// ComputeGlobalChecksum sums every byte of the ROM except the checksum itself
+func ComputeGlobalChecksum(rom []uint8) uint16 {
+	var sum uint16
+	for i, b := range rom {
+		if i != GlobalChecksum && i != GlobalChecksum+1 {
+			sum += uint16(b)
+		}
+	}
+	return sum
+}
+
+func (h *Header) LogoValid() bool {
+	return h.Logo == NintendoLogo
+}
+
+func (h *Header) HeaderChecksumValid() bool {
+	return h.HeaderChecksum == h.ComputedHeaderChecksum
+}
+
+func (h *Header) GlobalChecksumValid() bool {
+	return h.GlobalChecksum == h.ComputedGlobalChecksum
+}
+
+// Licensee returns the licensee code, which is in the two character field
+// when the old one byte code is 0x33
+func (h *Header) Licensee() string {
+	if h.OldLicenseeCode == UseNewLicensee {
+		return h.NewLicenseeCode
+	}
+	return fmt.Sprintf("%02X", h.OldLicenseeCode)
+}
+
+// Validate returns an error if the boot ROM would refuse to start the
+// cartridge. The global checksum is not verified by hardware, so a bad
+// one isn't an error here; check GlobalChecksumValid for that.
+func (h *Header) Validate() error {
+	var problems []string
+	if !h.LogoValid() {
+		problems = append(problems, "Nintendo logo mismatch")
+	}
+	if !h.HeaderChecksumValid() {
+		problems = append(problems, fmt.Sprintf("header checksum is 0x%02x, expected 0x%02x",
+			h.HeaderChecksum, h.ComputedHeaderChecksum))
+	}
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func validString(valid bool) string {
+	if valid {
+		return "ok"
+	}
+	return "BAD"
+}
+
+func (h *Header) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Title:            %s\n", h.Title)
+	if h.ManufacturerCode != "" {
+		fmt.Fprintf(&b, "Manufacturer:     %s\n", h.ManufacturerCode)
+	}
+	fmt.Fprintf(&b, "Licensee:         %s\n", h.Licensee())
+	fmt.Fprintf(&b, "CGB flag:         0x%02x\n", h.CGBFlag)
+	fmt.Fprintf(&b, "SGB flag:         0x%02x\n", h.SGBFlag)
+	fmt.Fprintf(&b, "Cartridge type:   0x%02x\n", h.CartridgeType)
+	fmt.Fprintf(&b, "ROM size:         0x%02x\n", h.ROMSize)
+	fmt.Fprintf(&b, "RAM size:         0x%02x\n", h.RAMSize)
+	fmt.Fprintf(&b, "Destination:      0x%02x\n", h.Destination)
+	fmt.Fprintf(&b, "Mask ROM version: 0x%02x\n", h.MaskROMVersion)
+	fmt.Fprintf(&b, "Nintendo logo:    %s\n", validString(h.LogoValid()))
+	fmt.Fprintf(&b, "Header checksum:  0x%02x %s\n", h.HeaderChecksum, validString(h.HeaderChecksumValid()))
+	fmt.Fprintf(&b, "Global checksum:  0x%04x %s\n", h.GlobalChecksum, validString(h.GlobalChecksumValid()))
+	return b.String()
+}
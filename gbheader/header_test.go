@@ -0,0 +1,98 @@
+package gbheader
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// makeROM returns a 32KB ROM with a valid logo, title and checksums
+func makeROM(title string) []uint8 {
+	rom := make([]uint8, 0x8000)
+	copy(rom[LogoStart:], NintendoLogo[:])
+	copy(rom[TitleStart:], title)
+	rom[CartridgeType] = 0x13
+	rom[ROMSize] = 0x00
+	rom[RAMSize] = 0x03
+	rom[Destination] = 0x01
+	rom[OldLicensee] = 0x01
+	rom[MaskROMVersion] = 0x02
+	rom[0x4000] = 0xaa
+	fixChecksums(rom)
+	return rom
+}
+
+func fixChecksums(rom []uint8) {
+	rom[HeaderChecksum] = ComputeHeaderChecksum(rom)
+	sum := ComputeGlobalChecksum(rom)
+	rom[GlobalChecksum] = uint8(sum >> 8)
+	rom[GlobalChecksum+1] = uint8(sum)
+}
+
+func TestParse(t *testing.T) {
+	h, err := Parse(makeROM("POKEMON RED"))
+	assert.Nil(t, err)
+	assert.Equal(t, "POKEMON RED", h.Title)
+	assert.Equal(t, "", h.ManufacturerCode)
+	assert.Equal(t, uint8(0x13), h.CartridgeType)
+	assert.Equal(t, uint8(0x03), h.RAMSize)
+	assert.Equal(t, uint8(0x01), h.Destination)
+	assert.Equal(t, uint8(0x02), h.MaskROMVersion)
+	assert.Equal(t, "01", h.Licensee())
+	assert.True(t, h.LogoValid())
+	assert.True(t, h.HeaderChecksumValid())
+	assert.True(t, h.GlobalChecksumValid())
+	assert.Nil(t, h.Validate())
+
+	_, err = Parse(make([]uint8, 0x014f))
+	assert.Equal(t, ErrTooShort, err)
+}
+
+func TestParseCGBTitle(t *testing.T) {
+	rom := makeROM("ZELDA")
+	copy(rom[ManufacturerEnd-4:], "AZ7E")
+	rom[CGBFlag] = CGBSupported
+	rom[OldLicensee] = UseNewLicensee
+	copy(rom[NewLicensee:], "01")
+	fixChecksums(rom)
+	h, err := Parse(rom)
+	assert.Nil(t, err)
+	assert.Equal(t, "ZELDA", h.Title)
+	assert.Equal(t, "AZ7E", h.ManufacturerCode)
+	assert.Equal(t, "01", h.Licensee())
+
+	/* without a manufacturer code the title runs up to the CGB flag */
+	rom = makeROM("SUPER MARIO BRO")
+	rom[CGBFlag] = CGBOnly
+	h, _ = Parse(rom)
+	assert.Equal(t, "SUPER MARIO BRO", h.Title)
+	assert.Equal(t, "", h.ManufacturerCode)
+}
+
+func TestChecksums(t *testing.T) {
+	rom := makeROM("TETRIS")
+	/* the header of Tetris v1.0 */
+	for i := TitleStart; i < HeaderChecksum; i++ {
+		rom[i] = 0
+	}
+	copy(rom[TitleStart:], "TETRIS")
+	rom[OldLicensee] = 0x01
+	assert.Equal(t, uint8(0x0b), ComputeHeaderChecksum(rom))
+
+	rom = makeROM("TETRIS")
+	rom[0x4000]++
+	h, _ := Parse(rom)
+	assert.True(t, h.HeaderChecksumValid())
+	assert.False(t, h.GlobalChecksumValid())
+	/* hardware doesn't check the global checksum */
+	assert.Nil(t, h.Validate())
+
+	rom[RAMSize] = 0x02
+	h, _ = Parse(rom)
+	assert.False(t, h.HeaderChecksumValid())
+	assert.EqualError(t, h.Validate(), "header checksum is 0xf2, expected 0xf3")
+
+	rom[LogoStart] = 0
+	h, _ = Parse(rom)
+	assert.False(t, h.LogoValid())
+	assert.Contains(t, h.Validate().Error(), "Nintendo logo mismatch")
+}
@@ -4,12 +4,13 @@ import (
 	"bytes"
 	"fmt"
 	"github.com/SrsBusiness/gobjdump"
+	"github.com/mukkid/GoBoy/gbheader"
 	"github.com/pborman/getopt/v2"
 	"io/ioutil"
 	"os"
 )
 
-var raw, gb *bool
+var raw, gb, header *bool
 
 func main_c(argv []string) int {
 	if len(argv) < 2 {
@@ -24,6 +25,10 @@ func main_c(argv []string) int {
 		return 1
 	}
 
+	if *header {
+		return printHeader(binData)
+	}
+
 	reader := bytes.NewReader(binData)
 
 	if *raw {
@@ -36,9 +41,26 @@ func main_c(argv []string) int {
 	}
 }
 
+// printHeader describes the cartridge header, failing if the boot ROM
+// would refuse to run it
+func printHeader(binData []byte) int {
+	h, err := gbheader.Parse(binData)
+	if err != nil {
+		fmt.Printf("%s\n", err.Error())
+		return 1
+	}
+	fmt.Print(h)
+	if err := h.Validate(); err != nil {
+		fmt.Printf("Invalid header: %s\n", err.Error())
+		return 1
+	}
+	return 0
+}
+
 func main() {
 	raw = getopt.BoolLong("raw", 'r', "Raw Z80 binary file")
 	gb = getopt.BoolLong("gbrom", 0, "GameBoy ROM file")
+	header = getopt.BoolLong("header", 'H', "Print and verify the GameBoy ROM header")
 	getopt.Parse()
 
 	if *raw && *gb {
@@ -46,8 +68,13 @@ func main() {
 		os.Exit(1)
 	}
 
+	if *raw && *header {
+		fmt.Printf("Cannot specify both --raw and --header\n")
+		os.Exit(1)
+	}
+
 	/* Raw file by default */
-	if !*raw && !*gb {
+	if !*raw && !*gb && !*header {
 		*raw = true
 	}
 
@@ -0,0 +1,80 @@
+package main
+
+/*
+ * Implements the MBC1 cartridge, up to 2MB ROM and 32KB RAM
+ *
+ * 0x0000 - 0x1fff  RAM enable, 0x0a in the low nibble enables
+ * 0x2000 - 0x3fff  lower 5 bits of the ROM bank, 0 selects 1
+ * 0x4000 - 0x5fff  RAM bank, or bits 5-6 of the ROM bank
+ * 0x6000 - 0x7fff  banking mode select
+ *
+ * Because the zero check only looks at the lower 5 bits, banks 0x20, 0x40
+ * and 0x60 can't be mapped at 0x4000 and select 0x21, 0x41 and 0x61.
+ */
+type GBMBC1 struct {
+	bankedROM
+	bankedRAM
+	ramEnabled bool
+	romBank    uint8 /* 5 bits */
+	upperBank  uint8 /* 2 bits */
+	/*
+	 * In mode 1 the upper bits also apply to 0x0000 - 0x3fff and select
+	 * the RAM bank; in mode 0 both of those use bank 0.
+	 */
+	advancedMode bool
+}
+
+func newGBMBC1(ramSize int) *GBMBC1 {
+	return &GBMBC1{
+		bankedRAM: newBankedRAM(ramSize),
+		romBank:   1,
+	}
+}
+
+func (r *GBMBC1) readROM(addr uint16) uint8 {
+	if addr < 0x4000 {
+		bank := 0
+		if r.advancedMode {
+			bank = int(r.upperBank) << 5
+		}
+		return r.readROMBank(bank, addr)
+	}
+	return r.readROMBank(int(r.upperBank)<<5|int(r.romBank), addr)
+}
+
+func (r *GBMBC1) writeROM(addr uint16, data uint8) {
+	switch {
+	case addr < 0x2000:
+		r.ramEnabled = data&0x0f == 0x0a
+	case addr < 0x4000:
+		r.romBank = data & 0x1f
+		if r.romBank == 0 {
+			r.romBank = 1
+		}
+	case addr < 0x6000:
+		r.upperBank = data & 0x03
+	default:
+		r.advancedMode = data&0x01 != 0
+	}
+}
+
+func (r *GBMBC1) ramBank() int {
+	if r.advancedMode {
+		return int(r.upperBank)
+	}
+	return 0
+}
+
+func (r *GBMBC1) readRAM(addr uint16) uint8 {
+	if !r.ramEnabled {
+		return 0xff
+	}
+	return r.readRAMBank(r.ramBank(), addr)
+}
+
+func (r *GBMBC1) writeRAM(addr uint16, data uint8) {
+	if !r.ramEnabled {
+		return
+	}
+	r.writeRAMBank(r.ramBank(), addr, data)
+}
@@ -0,0 +1,78 @@
+package main
+
+import "testing"
+import "github.com/stretchr/testify/assert"
+
+/* makeBankedROM returns a ROM image where byte 0 of every bank is its number */
+func makeBankedROM(banks int) []uint8 {
+	data := make([]uint8, banks*ROMBankSize)
+	for bank := 0; bank < banks; bank++ {
+		data[bank*ROMBankSize] = uint8(bank)
+		data[bank*ROMBankSize+1] = uint8(bank >> 8)
+	}
+	return data
+}
+
+func TestGBMBC1ROMBanking(t *testing.T) {
+	var r GBCartridge = newGBMBC1(0)
+	r.loadROM(makeBankedROM(128))
+	assert.Equal(t, uint8(0), r.readROM(0x0000))
+	assert.Equal(t, uint8(1), r.readROM(0x4000))
+	r.writeROM(0x2000, 0x05)
+	assert.Equal(t, uint8(5), r.readROM(0x4000))
+	// bank 0 selects bank 1
+	r.writeROM(0x3fff, 0x00)
+	assert.Equal(t, uint8(1), r.readROM(0x4000))
+	// only 5 bits are used
+	r.writeROM(0x2000, 0xe3)
+	assert.Equal(t, uint8(3), r.readROM(0x4000))
+	r.writeROM(0x4000, 0x02)
+	assert.Equal(t, uint8(0x43), r.readROM(0x4000))
+}
+
+func TestGBMBC1BankQuirk(t *testing.T) {
+	r := newGBMBC1(0)
+	r.loadROM(makeBankedROM(128))
+	for _, bank := range []uint8{0x20, 0x40, 0x60} {
+		r.writeROM(0x4000, bank>>5)
+		r.writeROM(0x2000, 0x00)
+		assert.Equal(t, bank+1, r.readROM(0x4000))
+	}
+}
+
+func TestGBMBC1Mode(t *testing.T) {
+	r := newGBMBC1(0x8000)
+	r.loadROM(makeBankedROM(128))
+	r.writeROM(0x0000, 0x0a)
+	r.writeROM(0x4000, 0x01)
+	// mode 0: 0x0000 is bank 0, RAM bank 0
+	r.writeRAM(0xa000, 0x11)
+	assert.Equal(t, uint8(0x00), r.readROM(0x0000))
+	// mode 1: 0x0000 is bank 0x20, RAM bank 1
+	r.writeROM(0x6000, 0x01)
+	assert.Equal(t, uint8(0x20), r.readROM(0x0000))
+	assert.Equal(t, uint8(0x00), r.readRAM(0xa000))
+	r.writeRAM(0xa000, 0x22)
+	r.writeROM(0x6000, 0x00)
+	assert.Equal(t, uint8(0x11), r.readRAM(0xa000))
+	assert.Equal(t, uint8(0x22), r.ram[RAMBankSize])
+}
+
+func TestGBMBC1RAMEnable(t *testing.T) {
+	r := newGBMBC1(0x2000)
+	r.loadROM(makeBankedROM(4))
+	r.writeRAM(0xa000, 0x42)
+	assert.Equal(t, uint8(0xff), r.readRAM(0xa000))
+	r.writeROM(0x1000, 0x1a)
+	r.writeRAM(0xa000, 0x42)
+	assert.Equal(t, uint8(0x42), r.readRAM(0xa000))
+	r.writeROM(0x0000, 0x00)
+	assert.Equal(t, uint8(0xff), r.readRAM(0xa000))
+}
+
+func TestGBMBC1SmallROMWraps(t *testing.T) {
+	r := newGBMBC1(0)
+	r.loadROM(makeBankedROM(4))
+	r.writeROM(0x2000, 0x06)
+	assert.Equal(t, uint8(2), r.readROM(0x4000))
+}
@@ -0,0 +1,62 @@
+package main
+
+/* MBC2 has 512 half-bytes of RAM built into the controller */
+const MBC2RAMSize = 512
+
+/*
+ * Implements the MBC2 cartridge, up to 256KB ROM
+ *
+ * 0x0000 - 0x3fff  with address bit 8 clear: RAM enable, 0x0a in the low
+ *                  nibble enables; with bit 8 set: 4-bit ROM bank, 0 selects 1
+ *
+ * Only the low nibble of each RAM byte exists, the upper one reads as 1s,
+ * and the 512 bytes are echoed throughout 0xa000 - 0xbfff.
+ */
+type GBMBC2 struct {
+	bankedROM
+	bankedRAM
+	ramEnabled bool
+	romBank    uint8
+}
+
+func newGBMBC2() *GBMBC2 {
+	return &GBMBC2{
+		bankedRAM: newBankedRAM(MBC2RAMSize),
+		romBank:   1,
+	}
+}
+
+func (r *GBMBC2) readROM(addr uint16) uint8 {
+	if addr < 0x4000 {
+		return r.readROMBank(0, addr)
+	}
+	return r.readROMBank(int(r.romBank), addr)
+}
+
+func (r *GBMBC2) writeROM(addr uint16, data uint8) {
+	if addr >= 0x4000 {
+		return
+	}
+	if addr&0x0100 == 0 {
+		r.ramEnabled = data&0x0f == 0x0a
+	} else {
+		r.romBank = data & 0x0f
+		if r.romBank == 0 {
+			r.romBank = 1
+		}
+	}
+}
+
+func (r *GBMBC2) readRAM(addr uint16) uint8 {
+	if !r.ramEnabled {
+		return 0xff
+	}
+	return r.readRAMBank(0, addr) | 0xf0
+}
+
+func (r *GBMBC2) writeRAM(addr uint16, data uint8) {
+	if !r.ramEnabled {
+		return
+	}
+	r.writeRAMBank(0, addr, data&0x0f)
+}
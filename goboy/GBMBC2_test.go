@@ -0,0 +1,41 @@
+package main
+
+import "testing"
+import "github.com/stretchr/testify/assert"
+
+func TestGBMBC2RegisterSelect(t *testing.T) {
+	var r GBCartridge = newGBMBC2()
+	r.loadROM(makeBankedROM(16))
+	// address bit 8 set: ROM bank
+	r.writeROM(0x2100, 0x05)
+	assert.Equal(t, uint8(5), r.readROM(0x4000))
+	r.writeROM(0x0100, 0xf3)
+	assert.Equal(t, uint8(3), r.readROM(0x4000))
+	r.writeROM(0x3fff, 0x00)
+	assert.Equal(t, uint8(1), r.readROM(0x4000))
+	// address bit 8 clear: RAM enable, not the ROM bank
+	r.writeROM(0x2000, 0x0a)
+	assert.Equal(t, uint8(1), r.readROM(0x4000))
+	r.writeRAM(0xa000, 0x07)
+	assert.Equal(t, uint8(0xf7), r.readRAM(0xa000))
+	// writes above 0x4000 do nothing
+	r.writeROM(0x4100, 0x02)
+	assert.Equal(t, uint8(1), r.readROM(0x4000))
+}
+
+func TestGBMBC2RAM(t *testing.T) {
+	r := newGBMBC2()
+	r.loadROM(makeBankedROM(2))
+	r.writeRAM(0xa000, 0x0c)
+	assert.Equal(t, uint8(0xff), r.readRAM(0xa000))
+	r.writeROM(0x0000, 0x0a)
+	// only the low nibble is stored
+	r.writeRAM(0xa010, 0xab)
+	assert.Equal(t, uint8(0xfb), r.readRAM(0xa010))
+	assert.Equal(t, uint8(0x0b), r.ram[0x010])
+	// echoed every 512 bytes
+	assert.Equal(t, uint8(0xfb), r.readRAM(0xa210))
+	assert.Equal(t, uint8(0xfb), r.readRAM(0xbe10))
+	r.writeRAM(0xbfff, 0x05)
+	assert.Equal(t, uint8(0xf5), r.readRAM(0xa1ff))
+}
@@ -0,0 +1,121 @@
+package main
+
+/*
+ * Implements the MBC3 cartridge, up to 2MB ROM, 32KB RAM and an optional
+ * real time clock
+ *
+ * 0x0000 - 0x1fff  RAM and RTC enable, 0x0a in the low nibble enables
+ * 0x2000 - 0x3fff  7-bit ROM bank, 0 selects 1
+ * 0x4000 - 0x5fff  0x00 - 0x03 select a RAM bank, 0x08 - 0x0c an RTC register
+ * 0x6000 - 0x7fff  writing 0x00 then 0x01 latches the RTC
+ */
+type GBMBC3 struct {
+	bankedROM
+	bankedRAM
+	rtc        *RTC /* nil for cartridges without a timer */
+	ramEnabled bool
+	romBank    uint8
+	ramSelect  uint8 /* RAM bank or RTC register */
+	latchWrite uint8 /* last value written to the latch register */
+}
+
+func newGBMBC3(ramSize int, rtc *RTC) *GBMBC3 {
+	return &GBMBC3{
+		bankedRAM:  newBankedRAM(ramSize),
+		rtc:        rtc,
+		romBank:    1,
+		latchWrite: 0xff,
+	}
+}
+
+func (r *GBMBC3) readROM(addr uint16) uint8 {
+	if addr < 0x4000 {
+		return r.readROMBank(0, addr)
+	}
+	return r.readROMBank(int(r.romBank), addr)
+}
+
+func (r *GBMBC3) writeROM(addr uint16, data uint8) {
+	switch {
+	case addr < 0x2000:
+		r.ramEnabled = data&0x0f == 0x0a
+	case addr < 0x4000:
+		r.romBank = data & 0x7f
+		if r.romBank == 0 {
+			r.romBank = 1
+		}
+	case addr < 0x6000:
+		r.ramSelect = data
+	default:
+		if r.rtc != nil && r.latchWrite == 0x00 && data == 0x01 {
+			r.rtc.latch()
+		}
+		r.latchWrite = data
+	}
+}
+
+// rtcRegister returns the selected RTC register, or -1 if a RAM bank is selected
+func (r *GBMBC3) rtcRegister() int {
+	if r.rtc == nil || r.ramSelect < 0x08 || r.ramSelect > 0x0c {
+		return -1
+	}
+	return int(r.ramSelect - 0x08)
+}
+
+func (r *GBMBC3) readRAM(addr uint16) uint8 {
+	if !r.ramEnabled {
+		return 0xff
+	}
+	if reg := r.rtcRegister(); reg >= 0 {
+		return r.rtc.read(reg)
+	}
+	if r.ramSelect > 0x03 {
+		return 0xff
+	}
+	return r.readRAMBank(int(r.ramSelect), addr)
+}
+
+func (r *GBMBC3) writeRAM(addr uint16, data uint8) {
+	if !r.ramEnabled {
+		return
+	}
+	if reg := r.rtcRegister(); reg >= 0 {
+		r.rtc.write(reg, data)
+		return
+	}
+	if r.ramSelect > 0x03 {
+		return
+	}
+	r.writeRAMBank(int(r.ramSelect), addr, data)
+}
+
+// tick drives the RTC in RTCEmulated mode
+func (r *GBMBC3) tick(cycles int) {
+	if r.rtc != nil {
+		r.rtc.tick(cycles)
+	}
+}
+
+// ramDirty is always true with a clock, as its time needs saving too
+func (r *GBMBC3) ramDirty() bool {
+	return r.rtc != nil || r.bankedRAM.ramDirty()
+}
+
+func (r *GBMBC3) saveRAM() []uint8 {
+	data := r.bankedRAM.saveRAM()
+	if r.rtc != nil {
+		data = append(data, r.rtc.marshal()...)
+	}
+	return data
+}
+
+// loadRAM accepts save files with or without the clock state at the end
+func (r *GBMBC3) loadRAM(data []uint8) error {
+	if r.rtc != nil && len(data) > len(r.ram) {
+		if err := r.rtc.unmarshal(data[len(r.ram):]); err != nil {
+			return err
+		}
+		data = data[:len(r.ram)]
+	}
+	return r.bankedRAM.loadRAM(data)
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestParseRTCMode(t *testing.T) {
+	mode, err := parseRTCMode("wall")
+	assert.Nil(t, err)
+	assert.Equal(t, RTCWallClock, mode)
+	mode, err = parseRTCMode("emulated")
+	assert.Nil(t, err)
+	assert.Equal(t, RTCEmulated, mode)
+	_, err = parseRTCMode("emulate")
+	assert.NotNil(t, err)
+}
+
+func TestGBMBC3ROMBanking(t *testing.T) {
+	var r GBCartridge = newGBMBC3(0, nil)
+	r.loadROM(makeBankedROM(128))
+	assert.Equal(t, uint8(1), r.readROM(0x4000))
+	r.writeROM(0x2000, 0x7f)
+	assert.Equal(t, uint8(0x7f), r.readROM(0x4000))
+	// 0x20 is reachable on MBC3
+	r.writeROM(0x2000, 0x20)
+	assert.Equal(t, uint8(0x20), r.readROM(0x4000))
+	r.writeROM(0x2000, 0x00)
+	assert.Equal(t, uint8(1), r.readROM(0x4000))
+	assert.Equal(t, uint8(0), r.readROM(0x0000))
+}
+
+func TestGBMBC3RAMBanking(t *testing.T) {
+	r := newGBMBC3(0x8000, nil)
+	r.loadROM(makeBankedROM(4))
+	r.writeROM(0x0000, 0x0a)
+	for bank := uint8(0); bank < 4; bank++ {
+		r.writeROM(0x4000, bank)
+		r.writeRAM(0xa123, bank+0x10)
+	}
+	for bank := uint8(0); bank < 4; bank++ {
+		r.writeROM(0x4000, bank)
+		assert.Equal(t, bank+0x10, r.readRAM(0xa123))
+	}
+	// no RTC on this cartridge
+	r.writeROM(0x4000, 0x08)
+	assert.Equal(t, uint8(0xff), r.readRAM(0xa000))
+	r.writeROM(0x0000, 0x00)
+	r.writeROM(0x4000, 0x00)
+	assert.Equal(t, uint8(0xff), r.readRAM(0xa123))
+}
+
+// readRTC latches the clock and reads back all of its registers
+func readRTC(r *GBMBC3) [5]uint8 {
+	var regs [5]uint8
+	r.writeROM(0x6000, 0x00)
+	r.writeROM(0x6000, 0x01)
+	for i := range regs {
+		r.writeROM(0x4000, uint8(0x08+i))
+		regs[i] = r.readRAM(0xa000)
+	}
+	return regs
+}
+
+func TestGBMBC3RTCEmulated(t *testing.T) {
+	r := newGBMBC3(0x2000, newRTC(RTCEmulated))
+	r.writeROM(0x0000, 0x0a)
+	r.tick(GBClockFrequency - 4)
+	assert.Equal(t, [5]uint8{0, 0, 0, 0, 0}, readRTC(r))
+	r.tick(4)
+	assert.Equal(t, [5]uint8{1, 0, 0, 0, 0}, readRTC(r))
+	r.tick(GBClockFrequency * 3600)
+	assert.Equal(t, [5]uint8{1, 0, 1, 0, 0}, readRTC(r))
+
+	// registers read the latched values until latched again
+	r.tick(GBClockFrequency)
+	r.writeROM(0x4000, 0x08)
+	assert.Equal(t, uint8(1), r.readRAM(0xa000))
+	assert.Equal(t, uint8(2), readRTC(r)[RTCSeconds])
+}
+
+func TestGBMBC3RTCDayCarry(t *testing.T) {
+	r := newGBMBC3(0, newRTC(RTCEmulated))
+	r.writeROM(0x0000, 0x0a)
+	// 511 days, 23:59:59
+	for reg, value := range []uint8{59, 59, 23, 0xff, RTCDayHighBit} {
+		r.writeROM(0x4000, uint8(0x08+reg))
+		r.writeRAM(0xa000, value)
+	}
+	r.tick(GBClockFrequency)
+	assert.Equal(t, [5]uint8{0, 0, 0, 0, RTCCarry}, readRTC(r))
+	r.tick(GBClockFrequency * 86400)
+	assert.Equal(t, [5]uint8{0, 0, 0, 1, RTCCarry}, readRTC(r))
+}
+
+func TestGBMBC3RTCHalt(t *testing.T) {
+	r := newGBMBC3(0, newRTC(RTCEmulated))
+	r.writeROM(0x0000, 0x0a)
+	r.writeROM(0x4000, 0x0c)
+	r.writeRAM(0xa000, RTCHalt)
+	r.tick(GBClockFrequency * 10)
+	assert.Equal(t, [5]uint8{0, 0, 0, 0, RTCHalt}, readRTC(r))
+	r.writeROM(0x4000, 0x0c)
+	r.writeRAM(0xa000, 0)
+	r.tick(GBClockFrequency * 10)
+	assert.Equal(t, uint8(10), readRTC(r)[RTCSeconds])
+}
+
+func TestGBMBC3RTCWallClock(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	rtc := newRTC(RTCWallClock)
+	rtc.now = func() time.Time { return now }
+	rtc.last = now
+	r := newGBMBC3(0, rtc)
+	r.writeROM(0x0000, 0x0a)
+	// emulated cycles don't matter
+	r.tick(GBClockFrequency * 5)
+	assert.Equal(t, uint8(0), readRTC(r)[RTCSeconds])
+	now = now.Add(26*time.Hour + 90*time.Second + 500*time.Millisecond)
+	assert.Equal(t, [5]uint8{30, 1, 2, 1, 0}, readRTC(r))
+	// the half second isn't lost
+	now = now.Add(500 * time.Millisecond)
+	assert.Equal(t, uint8(31), readRTC(r)[RTCSeconds])
+}
+
+func TestGBMBC3SaveRTC(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+	rtc := newRTC(RTCWallClock)
+	rtc.now = clock
+	rtc.last = now
+	r := newGBMBC3(0x2000, rtc)
+	r.writeROM(0x0000, 0x0a)
+	r.writeRAM(0xa010, 0x42)
+	now = now.Add(90 * time.Second)
+	data := r.saveRAM()
+	assert.Equal(t, 0x2000+RTCSaveSize, len(data))
+	assert.Equal(t, uint8(0x42), data[0x10])
+	/* clocks are always saved, so the cartridge is never clean */
+	assert.True(t, r.ramDirty())
+
+	// a day later the clock has caught up with the time switched off
+	now = now.Add(24 * time.Hour)
+	rtc = newRTC(RTCWallClock)
+	rtc.now = clock
+	r = newGBMBC3(0x2000, rtc)
+	assert.Nil(t, r.loadRAM(data))
+	r.writeROM(0x0000, 0x0a)
+	assert.Equal(t, uint8(0x42), r.readRAM(0xa010))
+	assert.Equal(t, [5]uint8{30, 1, 0, 1, 0}, readRTC(r))
+
+	// emulated clocks resume where they left off
+	r = newGBMBC3(0x2000, newRTC(RTCEmulated))
+	assert.Nil(t, r.loadRAM(data))
+	r.writeROM(0x0000, 0x0a)
+	assert.Equal(t, [5]uint8{30, 1, 0, 0, 0}, readRTC(r))
+
+	// saves without the clock state still load
+	assert.Nil(t, r.loadRAM(data[:0x2000]))
+	assert.NotNil(t, r.loadRAM(data[:0x2000+10]))
+}
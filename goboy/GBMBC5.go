@@ -0,0 +1,89 @@
+package main
+
+/*
+ * Implements the MBC5 cartridge, up to 8MB ROM and 128KB RAM
+ *
+ * 0x0000 - 0x1fff  RAM enable, 0x0a enables
+ * 0x2000 - 0x2fff  lower 8 bits of the ROM bank, bank 0 can be mapped at 0x4000
+ * 0x3000 - 0x3fff  bit 8 of the ROM bank
+ * 0x4000 - 0x5fff  RAM bank, bit 3 drives the motor on rumble cartridges
+ */
+type GBMBC5 struct {
+	bankedROM
+	bankedRAM
+	ramEnabled bool
+	romBank    uint16 /* 9 bits */
+	ramBank    uint8
+	rumble     bool
+	motorOn    bool
+	/*
+	 * OnRumble is called whenever a rumble cartridge switches its motor on
+	 * or off, for frontends that can shake a controller.
+	 */
+	OnRumble func(on bool)
+}
+
+func newGBMBC5(ramSize int, rumble bool) *GBMBC5 {
+	return &GBMBC5{
+		bankedRAM: newBankedRAM(ramSize),
+		romBank:   1,
+		rumble:    rumble,
+	}
+}
+
+func (r *GBMBC5) readROM(addr uint16) uint8 {
+	if addr < 0x4000 {
+		return r.readROMBank(0, addr)
+	}
+	return r.readROMBank(int(r.romBank), addr)
+}
+
+func (r *GBMBC5) writeROM(addr uint16, data uint8) {
+	switch {
+	case addr < 0x2000:
+		r.ramEnabled = data == 0x0a
+	case addr < 0x3000:
+		r.romBank = r.romBank&0x100 | uint16(data)
+	case addr < 0x4000:
+		r.romBank = r.romBank&0xff | uint16(data&0x01)<<8
+	case addr < 0x6000:
+		if r.rumble {
+			/* the motor takes the place of RAM bank bit 3 */
+			r.setMotor(data&0x08 != 0)
+			data &= 0x07
+		}
+		r.ramBank = data & 0x0f
+	}
+}
+
+func (r *GBMBC5) hasRumble() bool {
+	return r.rumble
+}
+
+func (r *GBMBC5) onRumble(f func(on bool)) {
+	r.OnRumble = f
+}
+
+func (r *GBMBC5) setMotor(on bool) {
+	if on == r.motorOn {
+		return
+	}
+	r.motorOn = on
+	if r.OnRumble != nil {
+		r.OnRumble(on)
+	}
+}
+
+func (r *GBMBC5) readRAM(addr uint16) uint8 {
+	if !r.ramEnabled {
+		return 0xff
+	}
+	return r.readRAMBank(int(r.ramBank), addr)
+}
+
+func (r *GBMBC5) writeRAM(addr uint16, data uint8) {
+	if !r.ramEnabled {
+		return
+	}
+	r.writeRAMBank(int(r.ramBank), addr, data)
+}
@@ -0,0 +1,58 @@
+package main
+
+import "testing"
+import "github.com/stretchr/testify/assert"
+
+func TestGBMBC5ROMBanking(t *testing.T) {
+	var r GBCartridge = newGBMBC5(0, false)
+	r.loadROM(makeBankedROM(512))
+	assert.Equal(t, uint8(1), r.readROM(0x4000))
+	// bank 0 can be mapped in the upper window
+	r.writeROM(0x2000, 0x00)
+	assert.Equal(t, uint8(0), r.readROM(0x4000))
+	r.writeROM(0x2000, 0xab)
+	r.writeROM(0x3000, 0x01)
+	assert.Equal(t, uint8(0xab), r.readROM(0x4000))
+	assert.Equal(t, uint8(0x01), r.readROM(0x4001))
+	r.writeROM(0x3000, 0x00)
+	assert.Equal(t, uint8(0xab), r.readROM(0x4000))
+	assert.Equal(t, uint8(0x00), r.readROM(0x4001))
+	assert.Equal(t, uint8(0), r.readROM(0x0000))
+}
+
+func TestGBMBC5RAMBanking(t *testing.T) {
+	r := newGBMBC5(0x20000, false)
+	r.loadROM(makeBankedROM(4))
+	r.writeRAM(0xa000, 0x42)
+	assert.Equal(t, uint8(0xff), r.readRAM(0xa000))
+	r.writeROM(0x0000, 0x0a)
+	for bank := uint8(0); bank < 16; bank++ {
+		r.writeROM(0x4000, bank)
+		r.writeRAM(0xbfff, bank)
+	}
+	r.writeROM(0x4000, 0x0f)
+	assert.Equal(t, uint8(0x0f), r.readRAM(0xbfff))
+	assert.Equal(t, uint8(0x0f), r.ram[0x1ffff])
+	r.writeROM(0x4000, 0x03)
+	assert.Equal(t, uint8(0x03), r.readRAM(0xbfff))
+}
+
+func TestGBMBC5Rumble(t *testing.T) {
+	r := newGBMBC5(0x8000, true)
+	var events []bool
+	r.OnRumble = func(on bool) { events = append(events, on) }
+	r.writeROM(0x0000, 0x0a)
+	r.writeROM(0x4000, 0x09)
+	r.writeRAM(0xa000, 0x42)
+	r.writeROM(0x4000, 0x0b)
+	r.writeROM(0x4000, 0x01)
+	assert.Equal(t, []bool{true, false}, events)
+	// bit 3 isn't part of the RAM bank
+	assert.Equal(t, uint8(0x42), r.readRAM(0xa000))
+	assert.Equal(t, uint8(0x42), r.ram[RAMBankSize])
+
+	plain := newGBMBC5(0x20000, false)
+	plain.OnRumble = func(on bool) { events = append(events, on) }
+	plain.writeROM(0x4000, 0x08)
+	assert.Equal(t, 2, len(events))
+}
@@ -0,0 +1,263 @@
+package main
+
+import "math"
+
+/* Sound I/O registers */
+const (
+	RegNR10 = 0xff10 // channel 1 sweep
+	RegNR11 = 0xff11 // channel 1 duty, length
+	RegNR12 = 0xff12 // channel 1 envelope
+	RegNR13 = 0xff13 // channel 1 frequency low
+	RegNR14 = 0xff14 // channel 1 trigger, length enable, frequency high
+	RegNR21 = 0xff16 // channel 2 duty, length
+	RegNR22 = 0xff17 // channel 2 envelope
+	RegNR23 = 0xff18 // channel 2 frequency low
+	RegNR24 = 0xff19 // channel 2 trigger, length enable, frequency high
+	RegNR30 = 0xff1a // channel 3 DAC enable
+	RegNR31 = 0xff1b // channel 3 length
+	RegNR32 = 0xff1c // channel 3 output level
+	RegNR33 = 0xff1d // channel 3 frequency low
+	RegNR34 = 0xff1e // channel 3 trigger, length enable, frequency high
+	RegNR41 = 0xff20 // channel 4 length
+	RegNR42 = 0xff21 // channel 4 envelope
+	RegNR43 = 0xff22 // channel 4 LFSR clock and width
+	RegNR44 = 0xff23 // channel 4 trigger, length enable
+	RegNR50 = 0xff24 // master volume
+	RegNR51 = 0xff25 // channel panning
+	RegNR52 = 0xff26 // power, channel status
+
+	WaveRAMStart = 0xff30
+	WaveRAMSize  = 0x10
+)
+
+const NR52Power = 0x80
+
+const (
+	/* clocks per output sample: a native rate of 131072Hz */
+	APUSampleDivider = 32
+	APUSampleRate    = GBClockFrequency / APUSampleDivider
+	/* samples handed to APU.Output at a time */
+	APUBufferSize = 1024
+
+	/* the frame sequencer runs at 512Hz */
+	FrameSequencerPeriod = GBClockFrequency / 512
+)
+
+type StereoSample struct {
+	Left, Right int16
+}
+
+/*
+ * The APU mixes two square channels, a wave channel and a noise channel.
+ * A 512Hz frame sequencer clocks the length counters (256Hz), channel 1's
+ * frequency sweep (128Hz) and the volume envelopes (64Hz). NR51 routes
+ * each channel to the left and/or right output and NR50 sets the volume of
+ * each side.
+ *
+ * On hardware the frame sequencer is clocked by bit 12 of the timer's
+ * divider; here it counts clocks on its own, so writing DIV doesn't
+ * disturb it.
+ */
+type APU struct {
+	mem      *GBMem
+	ch1      *squareChannel
+	ch2      *squareChannel
+	ch3      *waveChannel
+	ch4      *noiseChannel
+	power    bool
+	sequence int /* frame sequencer step, 0 - 7 */
+	seqTimer int /* clocks until the next frame sequencer step */
+	sampleIn int /* clocks until the next sample */
+	/* high pass filter removing the DC offset of the DACs, per side */
+	capacitor [2]float64
+	charge    float64
+	buffer    []StereoSample
+	/*
+	 * Output is called with every APUBufferSize samples produced at
+	 * APUSampleRate. The slice is reused once it returns. Samples are
+	 * dropped while it is nil.
+	 */
+	Output func(samples []StereoSample)
+}
+
+func newAPU(mem *GBMem) *APU {
+	a := &APU{
+		mem:      mem,
+		ch1:      newSquareChannel(mem, RegNR10, true),
+		ch2:      newSquareChannel(mem, RegNR21-1, false),
+		ch3:      newWaveChannel(mem),
+		ch4:      newNoiseChannel(mem),
+		charge:   math.Pow(0.999958, APUSampleDivider),
+		buffer:   make([]StereoSample, 0, APUBufferSize),
+		sampleIn: APUSampleDivider,
+	}
+	for addr := uint16(RegNR10); addr <= RegNR51; addr++ {
+		if _, ok := ioRegisters[addr]; ok {
+			addr := addr
+			mem.hookIO(addr, nil, func(value uint8) { a.writeRegister(addr, value) })
+		}
+	}
+	mem.hookIO(RegNR52, a.readNR52, a.writeNR52)
+	return a
+}
+
+/* Sound registers as the boot ROM leaves them after its chime */
+var apuBootRegisters = []struct {
+	addr  uint16
+	value uint8
+}{
+	{RegNR10, 0x00}, {RegNR11, 0x80}, {RegNR12, 0xf3}, {RegNR14, 0x00},
+	{RegNR21, 0x00}, {RegNR22, 0x00}, {RegNR24, 0x00},
+	{RegNR30, 0x00}, {RegNR32, 0x00}, {RegNR34, 0x00},
+	{RegNR41, 0x00}, {RegNR42, 0x00}, {RegNR43, 0x00}, {RegNR44, 0x00},
+	{RegNR50, 0x77}, {RegNR51, 0xf3},
+}
+
+// reset leaves the registers as the boot ROM does after its chime
+func (a *APU) reset() {
+	a.writeNR52(0)
+	a.mem.ioregs[RegNR52-0xff00] = NR52Power
+	a.writeNR52(NR52Power)
+	for _, r := range apuBootRegisters {
+		/* stored first, as on a bus write, so the channels see it */
+		a.mem.ioregs[r.addr-0xff00] = r.value
+		a.writeRegister(r.addr, r.value)
+	}
+	a.sampleIn = APUSampleDivider
+}
+
+func (a *APU) powered() bool {
+	return a.power
+}
+
+func (a *APU) channels() [4]channel {
+	return [4]channel{a.ch1, a.ch2, a.ch3, a.ch4}
+}
+
+// step advances the channels, stopping at each sample to mix the outputs
+func (a *APU) step(cycles int) {
+	for cycles > 0 {
+		n := min(cycles, a.sampleIn)
+		if a.powered() {
+			for _, ch := range a.channels() {
+				ch.step(n)
+			}
+			a.seqTimer -= n
+			if a.seqTimer <= 0 {
+				a.seqTimer += FrameSequencerPeriod
+				a.clockSequencer()
+			}
+		}
+		cycles -= n
+		a.sampleIn -= n
+		if a.sampleIn == 0 {
+			a.sampleIn = APUSampleDivider
+			a.mix()
+		}
+	}
+}
+
+func (a *APU) clockSequencer() {
+	if a.sequence%2 == 0 {
+		for _, ch := range a.channels() {
+			ch.clockLength()
+		}
+	}
+	if a.sequence == 2 || a.sequence == 6 {
+		a.ch1.clockSweep()
+	}
+	if a.sequence == 7 {
+		a.ch1.clockEnvelope()
+		a.ch2.clockEnvelope()
+		a.ch4.clockEnvelope()
+	}
+	a.sequence = (a.sequence + 1) % 8
+}
+
+// mix combines the channels into one output sample
+func (a *APU) mix() {
+	var out [2]float64
+	if a.powered() {
+		NR50 := a.mem.ioregs[RegNR50-0xff00]
+		NR51 := a.mem.ioregs[RegNR51-0xff00]
+		for i, ch := range a.channels() {
+			if !ch.dacEnabled() {
+				continue
+			}
+			/* the DACs map 0 - 15 onto 1.0 - -1.0 */
+			analog := 1 - float64(ch.output())/7.5
+			if NR51&(0x10<<uint(i)) != 0 {
+				out[0] += analog
+			}
+			if NR51&(0x01<<uint(i)) != 0 {
+				out[1] += analog
+			}
+		}
+		out[0] *= float64(NR50>>4&0x07+1) / 8
+		out[1] *= float64(NR50&0x07+1) / 8
+	}
+	var sample [2]int16
+	for i := range out {
+		filtered := out[i] - a.capacitor[i]
+		a.capacitor[i] = out[i] - filtered*a.charge
+		/* four channels at full volume can reach 4.0 */
+		sample[i] = int16(math.Max(-1, math.Min(1, filtered/4)) * math.MaxInt16)
+	}
+	a.buffer = append(a.buffer, StereoSample{sample[0], sample[1]})
+	if len(a.buffer) == APUBufferSize {
+		if a.Output != nil {
+			a.Output(a.buffer)
+		}
+		a.buffer = a.buffer[:0]
+	}
+}
+
+func (a *APU) writeRegister(addr uint16, value uint8) {
+	if !a.powered() {
+		/* while off the registers are held at 0 */
+		a.mem.ioregs[addr-0xff00] = 0
+		return
+	}
+	switch {
+	case addr <= RegNR14:
+		a.ch1.write(int(addr-RegNR10), value)
+	case addr <= RegNR24:
+		a.ch2.write(int(addr-RegNR21+1), value)
+	case addr <= RegNR34:
+		a.ch3.write(int(addr-RegNR30), value)
+	case addr <= RegNR44:
+		a.ch4.write(int(addr-RegNR41+1), value)
+	}
+}
+
+// readNR52 reports which channels are playing in the low bits
+func (a *APU) readNR52() uint8 {
+	value := a.mem.ioregs[RegNR52-0xff00] & NR52Power
+	for i, ch := range a.channels() {
+		if ch.playing() {
+			value |= 1 << uint(i)
+		}
+	}
+	return value
+}
+
+// writeNR52 switches the APU on or off. Powering off silences every
+// channel and clears NR10 - NR51, but wave RAM keeps its contents.
+func (a *APU) writeNR52(value uint8) {
+	power := value&NR52Power != 0
+	if power == a.power {
+		return
+	}
+	a.power = power
+	if power {
+		a.sequence = 0
+		a.seqTimer = FrameSequencerPeriod
+		return
+	}
+	for addr := uint16(RegNR10); addr <= RegNR51; addr++ {
+		a.mem.ioregs[addr-0xff00] = 0
+	}
+	for _, ch := range a.channels() {
+		ch.powerOff()
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func initAPU() *APU {
+	a := newAPU(&GBMem{})
+	a.reset()
+	return a
+}
+
+func TestAPUPower(t *testing.T) {
+	a := initAPU()
+	assert.Equal(t, uint8(0xf0), a.mem.read(RegNR52))
+	assert.Equal(t, uint8(0x77), a.mem.read(RegNR50))
+	a.mem.write(WaveRAMStart, 0x5a)
+
+	a.mem.write(RegNR52, 0x00)
+	assert.Equal(t, uint8(0x70), a.mem.read(RegNR52))
+	assert.Equal(t, uint8(0x00), a.mem.read(RegNR50))
+	// registers ignore writes while off, wave RAM doesn't
+	a.mem.write(RegNR50, 0x33)
+	assert.Equal(t, uint8(0x00), a.mem.read(RegNR50))
+	assert.Equal(t, uint8(0x5a), a.mem.read(WaveRAMStart))
+
+	a.mem.write(RegNR52, NR52Power)
+	a.mem.write(RegNR50, 0x33)
+	assert.Equal(t, uint8(0x33), a.mem.read(RegNR50))
+}
+
+func TestAPUReset(t *testing.T) {
+	// the channels see the boot ROM's writes, not just the registers
+	a := initAPU()
+	assert.Equal(t, 64, a.ch1.length)  // NR11 = 0x80
+	assert.True(t, a.ch1.dacEnabled()) // NR12 = 0xf3
+	a.mem.write(RegNR14, NRx4Trigger)
+	assert.Equal(t, uint8(0x0f), a.ch1.volume)
+	assert.Equal(t, uint8(0x01), a.mem.read(RegNR52)&0x0f)
+}
+
+func TestAPUSampleClock(t *testing.T) {
+	// samples are taken every APUSampleDivider clocks, reset or not
+	a := newAPU(&GBMem{})
+	a.step(APUSampleDivider - 1)
+	assert.Equal(t, 0, len(a.buffer))
+	a.step(1)
+	assert.Equal(t, 1, len(a.buffer))
+}
+
+// playSquare starts channel 2 at full volume with a period of 4 clocks
+func playSquare(a *APU, NR21 uint8) {
+	a.mem.write(RegNR21, NR21)
+	a.mem.write(RegNR22, 0xf0)
+	a.mem.write(RegNR23, 0xff)
+	a.mem.write(RegNR24, NRx4Trigger|NRx4LengthEnable|0x07)
+}
+
+func TestAPULength(t *testing.T) {
+	a := initAPU()
+	playSquare(a, 0x3e) // 2 length clocks
+	assert.Equal(t, uint8(0x02), a.mem.read(RegNR52)&0x0f)
+	// lengths are clocked on every other frame sequencer step
+	a.step(FrameSequencerPeriod)
+	assert.Equal(t, uint8(0x02), a.mem.read(RegNR52)&0x0f)
+	a.step(FrameSequencerPeriod * 2)
+	assert.Equal(t, uint8(0x00), a.mem.read(RegNR52)&0x0f)
+
+	// retriggering reloads an expired counter with the maximum
+	a.mem.write(RegNR24, NRx4Trigger|NRx4LengthEnable)
+	a.step(FrameSequencerPeriod * 2 * 63)
+	assert.Equal(t, uint8(0x02), a.mem.read(RegNR52)&0x0f)
+	a.step(FrameSequencerPeriod * 2)
+	assert.Equal(t, uint8(0x00), a.mem.read(RegNR52)&0x0f)
+}
+
+func TestAPUDAC(t *testing.T) {
+	a := initAPU()
+	a.mem.write(RegNR22, 0x08) // volume 0, but the DAC is on
+	a.mem.write(RegNR24, NRx4Trigger)
+	assert.Equal(t, uint8(0x02), a.mem.read(RegNR52)&0x0f)
+	a.mem.write(RegNR22, 0x00)
+	assert.Equal(t, uint8(0x00), a.mem.read(RegNR52)&0x0f)
+	// triggering doesn't start a channel with its DAC off
+	a.mem.write(RegNR24, NRx4Trigger)
+	assert.Equal(t, uint8(0x00), a.mem.read(RegNR52)&0x0f)
+}
+
+// captureAudio collects samples for the given number of buffers
+func captureAudio(a *APU, buffers int) []StereoSample {
+	var samples []StereoSample
+	a.Output = func(s []StereoSample) {
+		samples = append(samples, s...)
+	}
+	a.step(APUBufferSize * APUSampleDivider * buffers)
+	return samples
+}
+
+func TestAPUOutput(t *testing.T) {
+	a := initAPU()
+	a.mem.write(RegNR12, 0x00)
+	a.step(APUBufferSize*APUSampleDivider - 1)
+	samples := captureAudio(a, 1)
+	assert.Equal(t, APUBufferSize, len(samples))
+
+	// silence while every DAC is off
+	for _, s := range samples {
+		assert.Equal(t, StereoSample{0, 0}, s)
+	}
+
+	// a DAC left on by a silent channel is a DC offset, which is filtered out
+	a.mem.write(RegNR12, 0x08)
+	samples = captureAudio(a, 8)
+	var max int16
+	for _, s := range samples {
+		if s.Left > max {
+			max = s.Left
+		}
+	}
+	assert.True(t, max > 1000)
+	assert.True(t, samples[len(samples)-1].Left < 10)
+}
+
+func TestAPUPanning(t *testing.T) {
+	a := initAPU()
+	a.mem.write(RegNR51, 0x20) // channel 2 left only
+	a.mem.write(RegNR21, 0x80)
+	a.mem.write(RegNR22, 0xf0)
+	a.mem.write(RegNR23, 0x00) // 128Hz
+	a.mem.write(RegNR24, NRx4Trigger|0x06)
+	var left, right int
+	for _, s := range captureAudio(a, 2) {
+		if s.Left != 0 {
+			left++
+		}
+		if s.Right != 0 {
+			right++
+		}
+	}
+	assert.True(t, left > 0)
+	assert.Equal(t, 0, right)
+}
+
+func TestAPUVolume(t *testing.T) {
+	peak := func(NR50 uint8) int16 {
+		a := initAPU()
+		a.mem.write(RegNR50, NR50)
+		a.mem.write(RegNR51, 0x22)
+		a.mem.write(RegNR21, 0x80)
+		a.mem.write(RegNR22, 0xf0)
+		a.mem.write(RegNR24, NRx4Trigger|0x06)
+		var max int16
+		for _, s := range captureAudio(a, 1) {
+			if s.Left > max {
+				max = s.Left
+			}
+		}
+		return max
+	}
+	loud, quiet := peak(0x70), peak(0x10)
+	assert.True(t, quiet > 0)
+	assert.InDelta(t, float64(loud)/float64(quiet), 4.0, 0.01)
+}
@@ -0,0 +1,92 @@
+package main
+
+import "fmt"
+
+/* Host sample rate used unless -audio-rate says otherwise */
+const DefaultAudioRate = 44100
+
+/*
+ * An AudioSink consumes the emulator's sound as 16-bit stereo samples at
+ * a fixed rate. WriteSamples must not keep the slice after returning.
+ */
+type AudioSink interface {
+	WriteSamples(samples []StereoSample) error
+	Close() error
+}
+
+/* nullSink discards everything, for -audio-out null */
+type nullSink struct{}
+
+func (nullSink) WriteSamples([]StereoSample) error { return nil }
+func (nullSink) Close() error                      { return nil }
+
+/*
+ * Resampler converts the APU's native rate down to the host rate before
+ * passing samples on to a sink. Each output sample is the average of the
+ * input samples since the previous one, which also filters out most of
+ * what would otherwise alias. The rates are kept as integers so the
+ * output is exactly reproducible.
+ */
+type Resampler struct {
+	sink    AudioSink
+	inRate  int
+	outRate int
+	phase   int /* outRate units into the current output sample */
+	sum     [2]int
+	count   int
+	out     []StereoSample
+}
+
+func newResampler(sink AudioSink, inRate, outRate int) (*Resampler, error) {
+	if err := checkResample(inRate, outRate); err != nil {
+		return nil, err
+	}
+	return &Resampler{sink: sink, inRate: inRate, outRate: outRate}, nil
+}
+
+// checkResample reports whether inRate audio can be resampled to outRate
+func checkResample(inRate, outRate int) error {
+	if outRate <= 0 || outRate > inRate {
+		return fmt.Errorf("can't resample %dHz audio to %dHz", inRate, outRate)
+	}
+	return nil
+}
+
+func (r *Resampler) WriteSamples(samples []StereoSample) error {
+	r.out = r.out[:0]
+	for _, s := range samples {
+		r.sum[0] += int(s.Left)
+		r.sum[1] += int(s.Right)
+		r.count++
+		r.phase += r.outRate
+		if r.phase >= r.inRate {
+			r.phase -= r.inRate
+			r.out = append(r.out, StereoSample{
+				int16(r.sum[0] / r.count),
+				int16(r.sum[1] / r.count),
+			})
+			r.sum = [2]int{}
+			r.count = 0
+		}
+	}
+	if len(r.out) == 0 {
+		return nil
+	}
+	return r.sink.WriteSamples(r.out)
+}
+
+func (r *Resampler) Close() error {
+	return r.sink.Close()
+}
+
+// openAudioSink picks the sink for -audio-out: null discards the sound,
+// anything else is a WAV file. The rate is checked before a file is created.
+func openAudioSink(path string, rate int) (AudioSink, error) {
+	if err := checkResample(APUSampleRate, rate); err != nil {
+		return nil, err
+	}
+	if path == "null" {
+		return nullSink{}, nil
+	}
+	return createWAV(path, rate)
+}
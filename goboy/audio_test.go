@@ -0,0 +1,110 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+/* bufferSink keeps everything written to it */
+type bufferSink struct {
+	samples []StereoSample
+	closed  bool
+}
+
+func (b *bufferSink) WriteSamples(samples []StereoSample) error {
+	b.samples = append(b.samples, samples...)
+	return nil
+}
+
+func (b *bufferSink) Close() error {
+	b.closed = true
+	return nil
+}
+
+func TestResampler(t *testing.T) {
+	sink := &bufferSink{}
+	r, err := newResampler(sink, 4, 1)
+	assert.Nil(t, err)
+	r.WriteSamples([]StereoSample{{1, -1}, {2, -2}, {3, -3}, {6, -6}, {10, 0}})
+	assert.Equal(t, []StereoSample{{3, -3}}, sink.samples)
+	r.WriteSamples([]StereoSample{{10, 0}, {10, 0}, {10, 4}})
+	assert.Equal(t, []StereoSample{{3, -3}, {10, 1}}, sink.samples)
+	r.Close()
+	assert.True(t, sink.closed)
+
+	_, err = newResampler(sink, 44100, 48000)
+	assert.NotNil(t, err)
+	_, err = newResampler(sink, 44100, 0)
+	assert.NotNil(t, err)
+}
+
+func TestResamplerRate(t *testing.T) {
+	sink := &bufferSink{}
+	r, _ := newResampler(sink, APUSampleRate, DefaultAudioRate)
+	// one second in gives one second out, whatever the buffer size
+	second := make([]StereoSample, APUSampleRate)
+	for i := 0; i < len(second); i += 1000 {
+		end := i + 1000
+		if end > len(second) {
+			end = len(second)
+		}
+		r.WriteSamples(second[i:end])
+	}
+	assert.Equal(t, DefaultAudioRate, len(sink.samples))
+}
+
+func TestAttachAudioSink(t *testing.T) {
+	g := initProgram(0x18, 0xfe) // jr -2
+	sink := &bufferSink{}
+	assert.Nil(t, g.attachAudioSink(sink, 32768))
+	for g.TSC < GBClockFrequency {
+		g.Step()
+	}
+	/* whole APU buffers only */
+	assert.InDelta(t, 32768, len(sink.samples), APUBufferSize/4)
+	g.closeAudio()
+	assert.True(t, sink.closed)
+	assert.Nil(t, g.apu.Output)
+}
+
+func TestOpenAudioSink(t *testing.T) {
+	sink, err := openAudioSink("null", DefaultAudioRate)
+	assert.Nil(t, err)
+	assert.Equal(t, nullSink{}, sink)
+	assert.Nil(t, sink.WriteSamples(make([]StereoSample, APUBufferSize)))
+	assert.Nil(t, sink.Close())
+
+	// a bad rate is caught before the file is created
+	dir, err := ioutil.TempDir("", "goboy")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "sound.wav")
+	_, err = openAudioSink(path, 0)
+	assert.NotNil(t, err)
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestSetupAudio(t *testing.T) {
+	o := options{palette: DefaultColorScheme, rtc: "wall", audioRate: DefaultAudioRate}
+	g, cleanup, err := setup(&o)
+	assert.Nil(t, err)
+	defer cleanup()
+	// no -audio-out, nothing to resample
+	assert.Nil(t, g.audio)
+	assert.Nil(t, g.apu.Output)
+
+	o.audioOut = "null"
+	g, cleanup, err = setup(&o)
+	assert.Nil(t, err)
+	defer cleanup()
+	assert.NotNil(t, g.audio)
+	assert.NotNil(t, g.apu.Output)
+
+	o.audioRate = 0
+	_, _, err = setup(&o)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"io"
+)
+
+/* How a SerialCapture watching for pass/fail strings ended */
+type CaptureResult int
+
+const (
+	CaptureRunning CaptureResult = iota
+	CapturePassed
+	CaptureFailed
+)
+
+/*
+ * SerialCapture copies bytes sent over the serial port to a writer, the
+ * way test ROMs such as Blargg's report their results. If pass or fail
+ * strings are set, Result records which of them was sent first.
+ */
+type SerialCapture struct {
+	out    io.Writer
+	pass   []uint8
+	fail   []uint8
+	recent []uint8 /* the last bytes sent, enough to match either string */
+	Result CaptureResult
+}
+
+func newSerialCapture(out io.Writer, pass, fail string) *SerialCapture {
+	return &SerialCapture{out: out, pass: []uint8(pass), fail: []uint8(fail)}
+}
+
+func (c *SerialCapture) write(data uint8) {
+	if c.out != nil {
+		c.out.Write([]uint8{data})
+	}
+	if c.Result != CaptureRunning {
+		return
+	}
+	keep := len(c.pass)
+	if len(c.fail) > keep {
+		keep = len(c.fail)
+	}
+	c.recent = append(c.recent, data)
+	if len(c.recent) > keep {
+		c.recent = c.recent[len(c.recent)-keep:]
+	}
+	switch {
+	case len(c.pass) > 0 && bytes.HasSuffix(c.recent, c.pass):
+		c.Result = CapturePassed
+	case len(c.fail) > 0 && bytes.HasSuffix(c.recent, c.fail):
+		c.Result = CaptureFailed
+	}
+}
+
+// watching reports whether there is a pass or fail string to wait for
+func (c *SerialCapture) watching() bool {
+	return len(c.pass) > 0 || len(c.fail) > 0
+}
+
+// exitStatus is 0 for a pass, 1 for a failure and 2 if neither was seen
+// while waiting for one
+func (c *SerialCapture) exitStatus() int {
+	switch {
+	case c.Result == CapturePassed:
+		return 0
+	case c.Result == CaptureFailed:
+		return 1
+	case c.watching():
+		return 2
+	}
+	return 0
+}
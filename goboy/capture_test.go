@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestSerialCapture(t *testing.T) {
+	var out bytes.Buffer
+	c := newSerialCapture(&out, "Passed", "Failed")
+	for _, b := range []uint8("cpu_instrs\n\n01:ok  02:ok\n\nPass") {
+		c.write(b)
+	}
+	assert.Equal(t, CaptureRunning, c.Result)
+	assert.Equal(t, 2, c.exitStatus())
+	for _, b := range []uint8("ed all tests\n") {
+		c.write(b)
+	}
+	assert.Equal(t, CapturePassed, c.Result)
+	assert.Equal(t, 0, c.exitStatus())
+	assert.Equal(t, "cpu_instrs\n\n01:ok  02:ok\n\nPassed all tests\n", out.String())
+
+	c = newSerialCapture(nil, "Passed", "Failed")
+	for _, b := range []uint8("03:Failed #2") {
+		c.write(b)
+	}
+	assert.Equal(t, CaptureFailed, c.Result)
+	assert.Equal(t, 1, c.exitStatus())
+
+	// without strings to look for, capturing never fails
+	c = newSerialCapture(&out, "", "")
+	c.write('x')
+	assert.Equal(t, CaptureRunning, c.Result)
+	assert.Equal(t, 0, c.exitStatus())
+}
+
+func TestCaptureSerial(t *testing.T) {
+	// print "ok" the way test ROMs do, then loop forever
+	gb := initProgram(
+		0x3e, 'o', // ld a, 'o'
+		0xe0, 0x01, // ldh (SB), a
+		0x3e, 0x81, // ld a, 0x81
+		0xe0, 0x02, // ldh (SC), a
+		0x3e, 'k', // ld a, 'k'
+		0xe0, 0x01, // ldh (SB), a
+		0x3e, 0x81, // ld a, 0x81
+		0xe0, 0x02, // ldh (SC), a
+		0x18, 0xfe, // jr -2
+	)
+	var out bytes.Buffer
+	gb.captureSerial(newSerialCapture(&out, "ok", ""))
+	gb.Paused = false
+	for i := 0; i < 100 && !gb.Paused; i++ {
+		gb.Step()
+	}
+	assert.True(t, gb.Paused)
+	assert.True(t, gb.finished())
+	assert.Equal(t, "ok", out.String())
+	assert.Equal(t, uint16(0xff90), gb.get16Reg(PC))
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"github.com/mukkid/GoBoy/gbheader"
+	"io/ioutil"
+)
+
+/* Cartridge header fields describing the hardware */
+const (
+	HeaderCartridgeType = gbheader.CartridgeType
+	HeaderROMSize       = gbheader.ROMSize
+	HeaderRAMSize       = gbheader.RAMSize
+)
+
+var cartridgeTypeNames = map[GBCartridgeType]string{
+	GBCartridgeROM:                  "ROM ONLY",
+	GBCartridgeMBC1:                 "MBC1",
+	GBCartridgeMBC1RAM:              "MBC1+RAM",
+	GBCartridgeMBC1RAMBattery:       "MBC1+RAM+BATTERY",
+	GBCartridgeMBC2:                 "MBC2",
+	GBCartridgeMBC2RAMBattery:       "MBC2+BATTERY",
+	GBCartridgeROMRAM:               "ROM+RAM",
+	GBCartridgeROMRAMBattery:        "ROM+RAM+BATTERY",
+	GBCartridgeMMM01:                "MMM01",
+	GBCartridgeMMM01RAM:             "MMM01+RAM",
+	GBCartridgeMMM01RAMBattery:      "MMM01+RAM+BATTERY",
+	GBCartridgeMBC3TimerBattery:     "MBC3+TIMER+BATTERY",
+	GBCartridgeMBC3RAMTimerBattery:  "MBC3+TIMER+RAM+BATTERY",
+	GBCartridgeMBC3:                 "MBC3",
+	GBCartridgeMBC3RAM:              "MBC3+RAM",
+	GBCartridgeMBC3RAMBattery:       "MBC3+RAM+BATTERY",
+	GBCartridgeMBC5:                 "MBC5",
+	GBCartridgeMBC5RAM:              "MBC5+RAM",
+	GBCartridgeMBC5RAMBattery:       "MBC5+RAM+BATTERY",
+	GBCartridgeMBC5Rumble:           "MBC5+RUMBLE",
+	GBCartridgeMBC5RAMRumble:        "MBC5+RUMBLE+RAM",
+	GBCartridgeMBC5RAMBatteryRumble: "MBC5+RUMBLE+RAM+BATTERY",
+	GBCartridgeMBC6RAMBattery:       "MBC6",
+	GBCartridgeMBC7RAMBatAccel:      "MBC7+SENSOR+RUMBLE+RAM+BATTERY",
+	GBCartridgePocketCamera:         "POCKET CAMERA",
+	GBCartridgeBandaiTAMA5:          "BANDAI TAMA5",
+	GBCartridgeHuC3:                 "HuC3",
+	GBCartridgeHuC1RAMBattery:       "HuC1+RAM+BATTERY",
+}
+
+func (t GBCartridgeType) String() string {
+	if name, ok := cartridgeTypeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("unknown (0x%02x)", uint8(t))
+}
+
+/* Cartridge types with a battery keeping their RAM (or clock) alive */
+var batteryTypes = map[GBCartridgeType]bool{
+	GBCartridgeMBC1RAMBattery:       true,
+	GBCartridgeMBC2RAMBattery:       true,
+	GBCartridgeROMRAMBattery:        true,
+	GBCartridgeMMM01RAMBattery:      true,
+	GBCartridgeMBC3TimerBattery:     true,
+	GBCartridgeMBC3RAMTimerBattery:  true,
+	GBCartridgeMBC3RAMBattery:       true,
+	GBCartridgeMBC5RAMBattery:       true,
+	GBCartridgeMBC5RAMBatteryRumble: true,
+	GBCartridgeMBC6RAMBattery:       true,
+	GBCartridgeMBC7RAMBatAccel:      true,
+	GBCartridgeHuC1RAMBattery:       true,
+}
+
+func (t GBCartridgeType) hasBattery() bool {
+	return batteryTypes[t]
+}
+
+/* RAM size specified at 0x0149 */
+var ramSizes = map[uint8]int{
+	0x00: 0,
+	0x01: 0x800, /* unofficial 2KB */
+	0x02: 0x2000,
+	0x03: 0x8000,
+	0x04: 0x20000,
+	0x05: 0x10000,
+}
+
+// romSize decodes 0x0148, computed as 32KB << n
+func romSize(code uint8) (int, error) {
+	if code > 0x08 {
+		return 0, fmt.Errorf("invalid ROM size code 0x%02x", code)
+	}
+	return 0x8000 << code, nil
+}
+
+func ramSize(code uint8) (int, error) {
+	size, ok := ramSizes[code]
+	if !ok {
+		return 0, fmt.Errorf("invalid RAM size code 0x%02x", code)
+	}
+	return size, nil
+}
+
+// newCartridge picks the GBCartridge implementation for a ROM image from
+// its header and loads the image into it
+func newCartridge(data []uint8, rtcMode RTCMode) (GBCartridge, error) {
+	header, err := gbheader.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	return newCartridgeFromHeader(header, data, rtcMode)
+}
+
+func newCartridgeFromHeader(header *gbheader.Header, data []uint8, rtcMode RTCMode) (GBCartridge, error) {
+	cartType := GBCartridgeType(header.CartridgeType)
+	romBytes, err := romSize(header.ROMSize)
+	if err != nil {
+		return nil, err
+	}
+	ramBytes, err := ramSize(header.RAMSize)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > romBytes {
+		return nil, fmt.Errorf("ROM is %d bytes but the header says %d", len(data), romBytes)
+	}
+
+	var cartridge GBCartridge
+	switch cartType {
+	case GBCartridgeROM:
+		if romBytes > 0x8000 {
+			return nil, fmt.Errorf("ROM only cartridge can't hold %d bytes", romBytes)
+		}
+		cartridge = newGBROM()
+	case GBCartridgeMBC1, GBCartridgeMBC1RAM, GBCartridgeMBC1RAMBattery:
+		cartridge = newGBMBC1(ramBytes)
+	case GBCartridgeMBC2, GBCartridgeMBC2RAMBattery:
+		cartridge = newGBMBC2()
+	case GBCartridgeMBC3TimerBattery, GBCartridgeMBC3RAMTimerBattery:
+		cartridge = newGBMBC3(ramBytes, newRTC(rtcMode))
+	case GBCartridgeMBC3, GBCartridgeMBC3RAM, GBCartridgeMBC3RAMBattery:
+		cartridge = newGBMBC3(ramBytes, nil)
+	case GBCartridgeMBC5, GBCartridgeMBC5RAM, GBCartridgeMBC5RAMBattery:
+		cartridge = newGBMBC5(ramBytes, false)
+	case GBCartridgeMBC5Rumble, GBCartridgeMBC5RAMRumble, GBCartridgeMBC5RAMBatteryRumble:
+		cartridge = newGBMBC5(ramBytes, true)
+	default:
+		return nil, fmt.Errorf("unsupported cartridge type %s", cartType)
+	}
+	/* pad to the size in the header so bank numbers wrap correctly */
+	rom := make([]uint8, romBytes)
+	copy(rom, data)
+	if err := cartridge.loadROM(rom); err != nil {
+		return nil, err
+	}
+	return cartridge, nil
+}
+
+// loadCartridge reads a ROM file, returning its header alongside the
+// cartridge so the caller can report on it
+func loadCartridge(fname string, rtcMode RTCMode) (GBCartridge, *gbheader.Header, error) {
+	data, err := ioutil.ReadFile(fname)
+	if err != nil {
+		return nil, nil, err
+	}
+	header, err := gbheader.Parse(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	cartridge, err := newCartridgeFromHeader(header, data, rtcMode)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cartridge, header, nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// makeHeaderROM returns a ROM image of the given size with header bytes set
+func makeHeaderROM(size int, cartType GBCartridgeType, romCode, ramCode uint8) []uint8 {
+	data := make([]uint8, size)
+	data[HeaderCartridgeType] = uint8(cartType)
+	data[HeaderROMSize] = romCode
+	data[HeaderRAMSize] = ramCode
+	return data
+}
+
+func TestCartridgeTypeValues(t *testing.T) {
+	assert.Equal(t, uint8(0x01), uint8(GBCartridgeMBC1))
+	assert.Equal(t, uint8(0x06), uint8(GBCartridgeMBC2RAMBattery))
+	assert.Equal(t, uint8(0x13), uint8(GBCartridgeMBC3RAMBattery))
+	assert.Equal(t, uint8(0x1e), uint8(GBCartridgeMBC5RAMBatteryRumble))
+	assert.Equal(t, uint8(0xff), uint8(GBCartridgeHuC1RAMBattery))
+	assert.Equal(t, "MBC3+TIMER+RAM+BATTERY", GBCartridgeMBC3RAMTimerBattery.String())
+	assert.Equal(t, "unknown (0x42)", GBCartridgeType(0x42).String())
+}
+
+func TestNewCartridge(t *testing.T) {
+	cart, err := newCartridge(makeHeaderROM(0x8000, GBCartridgeROM, 0, 0), RTCEmulated)
+	assert.Nil(t, err)
+	assert.IsType(t, &GBROM{}, cart)
+
+	cart, err = newCartridge(makeHeaderROM(0x40000, GBCartridgeMBC1RAMBattery, 3, 3), RTCEmulated)
+	assert.Nil(t, err)
+	assert.Equal(t, 0x40000, len(cart.(*GBMBC1).rom))
+	assert.Equal(t, 0x8000, len(cart.(*GBMBC1).ram))
+
+	cart, err = newCartridge(makeHeaderROM(0x40000, GBCartridgeMBC2, 3, 0), RTCEmulated)
+	assert.Nil(t, err)
+	assert.Equal(t, MBC2RAMSize, len(cart.(*GBMBC2).ram))
+
+	cart, err = newCartridge(makeHeaderROM(0x8000, GBCartridgeMBC3RAMTimerBattery, 0, 2), RTCEmulated)
+	assert.Nil(t, err)
+	assert.NotNil(t, cart.(*GBMBC3).rtc)
+	assert.Equal(t, RTCEmulated, cart.(*GBMBC3).rtc.mode)
+	cart, err = newCartridge(makeHeaderROM(0x8000, GBCartridgeMBC3RAM, 0, 2), RTCEmulated)
+	assert.Nil(t, err)
+	assert.Nil(t, cart.(*GBMBC3).rtc)
+
+	cart, err = newCartridge(makeHeaderROM(0x8000, GBCartridgeMBC5RAMRumble, 0, 4), RTCEmulated)
+	assert.Nil(t, err)
+	assert.True(t, cart.(*GBMBC5).rumble)
+	assert.Equal(t, 0x20000, len(cart.(*GBMBC5).ram))
+}
+
+func TestNewCartridgePadsROM(t *testing.T) {
+	// a trimmed dump is padded to the size in the header
+	data := makeHeaderROM(0x10000, GBCartridgeMBC1, 2, 0)
+	cart, err := newCartridge(data, RTCEmulated)
+	assert.Nil(t, err)
+	assert.Equal(t, 0x20000, len(cart.(*GBMBC1).rom))
+}
+
+func TestNewCartridgeErrors(t *testing.T) {
+	_, err := newCartridge(make([]uint8, 0x100), RTCEmulated)
+	assert.NotNil(t, err)
+	_, err = newCartridge(makeHeaderROM(0x8000, GBCartridgeHuC3, 0, 0), RTCEmulated)
+	assert.EqualError(t, err, "unsupported cartridge type HuC3")
+	_, err = newCartridge(makeHeaderROM(0x8000, GBCartridgeType(0x42), 0, 0), RTCEmulated)
+	assert.EqualError(t, err, "unsupported cartridge type unknown (0x42)")
+	_, err = newCartridge(makeHeaderROM(0x8000, GBCartridgeMBC1, 0x20, 0), RTCEmulated)
+	assert.NotNil(t, err)
+	_, err = newCartridge(makeHeaderROM(0x8000, GBCartridgeMBC1, 0, 0x09), RTCEmulated)
+	assert.NotNil(t, err)
+	_, err = newCartridge(makeHeaderROM(0x10000, GBCartridgeROM, 0, 0), RTCEmulated)
+	assert.NotNil(t, err)
+	_, err = newCartridge(makeHeaderROM(0x10000, GBCartridgeROM, 1, 0), RTCEmulated)
+	assert.NotNil(t, err)
+}
+
+func TestCartridgeBattery(t *testing.T) {
+	assert.True(t, GBCartridgeMBC1RAMBattery.hasBattery())
+	assert.True(t, GBCartridgeMBC3TimerBattery.hasBattery())
+	assert.False(t, GBCartridgeMBC1RAM.hasBattery())
+	assert.False(t, GBCartridgeROM.hasBattery())
+}
@@ -0,0 +1,386 @@
+package main
+
+/*
+ * Every channel has five registers NRx0 - NRx4, some of them unused:
+ *
+ * NRx0  channel 1: sweep, channel 3: DAC enable
+ * NRx1  length (and duty for the square channels)
+ * NRx2  volume envelope (channel 3: output level)
+ * NRx3  frequency low bits (channel 4: LFSR clock)
+ * NRx4  trigger, length enable and frequency high bits
+ */
+const (
+	NRx4Trigger      = 0x80
+	NRx4LengthEnable = 0x40
+)
+
+type channel interface {
+	step(cycles int)
+	clockLength()
+	/* output is the current 4-bit digital sample */
+	output() uint8
+	dacEnabled() bool
+	playing() bool
+	powerOff()
+}
+
+/* Shared by the channels: register access, length counter and envelope */
+type channelBase struct {
+	mem     *GBMem
+	base    uint16 /* address of NRx0 */
+	enabled bool
+	length  int
+	/* envelope */
+	volume   uint8
+	envTimer uint8
+}
+
+func (c *channelBase) reg(i int) uint8 {
+	return c.mem.ioregs[c.base+uint16(i)-0xff00]
+}
+
+func (c *channelBase) frequency() int {
+	return int(c.reg(4)&0x07)<<8 | int(c.reg(3))
+}
+
+func (c *channelBase) playing() bool {
+	return c.enabled
+}
+
+// clockLength counts down when enabled, switching the channel off at 0
+func (c *channelBase) clockLength() {
+	if c.reg(4)&NRx4LengthEnable == 0 || c.length == 0 {
+		return
+	}
+	c.length--
+	if c.length == 0 {
+		c.enabled = false
+	}
+}
+
+/* NRx2: initial volume in bits 4-7, bit 3 set increases, period in bits 0-2 */
+func (c *channelBase) envelopeDAC() bool {
+	return c.reg(2)&0xf8 != 0
+}
+
+func (c *channelBase) triggerEnvelope() {
+	c.volume = c.reg(2) >> 4
+	c.envTimer = envelopePeriod(c.reg(2))
+}
+
+func envelopePeriod(NRx2 uint8) uint8 {
+	if NRx2&0x07 == 0 {
+		return 8
+	}
+	return NRx2 & 0x07
+}
+
+func (c *channelBase) clockEnvelope() {
+	NRx2 := c.reg(2)
+	if NRx2&0x07 == 0 {
+		return
+	}
+	c.envTimer--
+	if c.envTimer > 0 {
+		return
+	}
+	c.envTimer = envelopePeriod(NRx2)
+	if NRx2&0x08 != 0 && c.volume < 15 {
+		c.volume++
+	} else if NRx2&0x08 == 0 && c.volume > 0 {
+		c.volume--
+	}
+}
+
+/* Duty cycles of the square channels, 12.5%, 25%, 50% and 75% */
+var dutyPatterns = [4]uint8{0x01, 0x81, 0x87, 0x7e}
+
+/*
+ * Channels 1 and 2 play a square wave, stepping through the 8 steps of the
+ * duty pattern every (2048 - frequency) * 4 clocks. Channel 1 also has a
+ * sweep unit that periodically shifts its frequency up or down.
+ */
+type squareChannel struct {
+	channelBase
+	timer   int
+	dutyPos uint
+	/* sweep, channel 1 only */
+	hasSweep     bool
+	shadow       int
+	sweepTimer   uint8
+	sweepEnabled bool
+}
+
+func newSquareChannel(mem *GBMem, base uint16, sweep bool) *squareChannel {
+	return &squareChannel{
+		channelBase: channelBase{mem: mem, base: base},
+		hasSweep:    sweep,
+	}
+}
+
+func (c *squareChannel) period() int {
+	return (2048 - c.frequency()) * 4
+}
+
+func (c *squareChannel) step(cycles int) {
+	c.timer -= cycles
+	for c.timer <= 0 {
+		c.timer += c.period()
+		c.dutyPos = (c.dutyPos + 1) % 8
+	}
+}
+
+func (c *squareChannel) output() uint8 {
+	if !c.enabled || dutyPatterns[c.reg(1)>>6]>>(7-c.dutyPos)&1 == 0 {
+		return 0
+	}
+	return c.volume
+}
+
+func (c *squareChannel) dacEnabled() bool {
+	return c.envelopeDAC()
+}
+
+func (c *squareChannel) write(reg int, value uint8) {
+	switch reg {
+	case 1:
+		c.length = 64 - int(value&0x3f)
+	case 2:
+		if !c.dacEnabled() {
+			c.enabled = false
+		}
+	case 4:
+		if value&NRx4Trigger != 0 {
+			c.trigger()
+		}
+	}
+}
+
+func (c *squareChannel) trigger() {
+	c.enabled = c.dacEnabled()
+	if c.length == 0 {
+		c.length = 64
+	}
+	c.timer = c.period()
+	c.triggerEnvelope()
+	if c.hasSweep {
+		NR10 := c.reg(0)
+		c.shadow = c.frequency()
+		c.sweepTimer = sweepPeriod(NR10)
+		c.sweepEnabled = NR10&0x77 != 0
+		if NR10&0x07 != 0 {
+			c.sweepFrequency()
+		}
+	}
+}
+
+/* NR10: period in bits 4-6, bit 3 set decreases, shift in bits 0-2 */
+func sweepPeriod(NR10 uint8) uint8 {
+	if NR10&0x70 == 0 {
+		return 8
+	}
+	return NR10 >> 4 & 0x07
+}
+
+// sweepFrequency calculates the next frequency, disabling the channel if
+// it overflows 11 bits
+func (c *squareChannel) sweepFrequency() int {
+	NR10 := c.reg(0)
+	delta := c.shadow >> (NR10 & 0x07)
+	freq := c.shadow + delta
+	if NR10&0x08 != 0 {
+		freq = c.shadow - delta
+	}
+	if freq > 2047 {
+		c.enabled = false
+	}
+	return freq
+}
+
+func (c *squareChannel) clockSweep() {
+	c.sweepTimer--
+	if c.sweepTimer > 0 {
+		return
+	}
+	NR10 := c.reg(0)
+	c.sweepTimer = sweepPeriod(NR10)
+	if !c.sweepEnabled || NR10&0x70 == 0 {
+		return
+	}
+	freq := c.sweepFrequency()
+	if freq <= 2047 && NR10&0x07 != 0 {
+		c.shadow = freq
+		c.mem.ioregs[c.base+3-0xff00] = uint8(freq)
+		c.mem.ioregs[c.base+4-0xff00] = c.reg(4)&^0x07 | uint8(freq>>8)
+		/* the new frequency is checked for overflow straight away */
+		c.sweepFrequency()
+	}
+}
+
+func (c *squareChannel) powerOff() {
+	c.enabled = false
+	c.length = 0
+	c.dutyPos = 0
+	c.sweepEnabled = false
+}
+
+/* NR32 output level shifts: mute, 100%, 50%, 25% */
+var waveShifts = [4]uint{4, 0, 1, 2}
+
+/*
+ * Channel 3 plays the 32 4-bit samples in wave RAM, high nibble first,
+ * one every (2048 - frequency) * 2 clocks.
+ */
+type waveChannel struct {
+	channelBase
+	timer    int
+	position int
+}
+
+func newWaveChannel(mem *GBMem) *waveChannel {
+	return &waveChannel{channelBase: channelBase{mem: mem, base: RegNR30}}
+}
+
+func (c *waveChannel) period() int {
+	return (2048 - c.frequency()) * 2
+}
+
+func (c *waveChannel) step(cycles int) {
+	c.timer -= cycles
+	for c.timer <= 0 {
+		c.timer += c.period()
+		c.position = (c.position + 1) % 32
+	}
+}
+
+func (c *waveChannel) output() uint8 {
+	if !c.enabled {
+		return 0
+	}
+	sample := c.mem.ioregs[WaveRAMStart+uint16(c.position/2)-0xff00]
+	if c.position%2 == 0 {
+		sample >>= 4
+	}
+	return sample & 0x0f >> waveShifts[c.reg(2)>>5&0x03]
+}
+
+func (c *waveChannel) dacEnabled() bool {
+	return c.reg(0)&0x80 != 0
+}
+
+func (c *waveChannel) write(reg int, value uint8) {
+	switch reg {
+	case 0:
+		if !c.dacEnabled() {
+			c.enabled = false
+		}
+	case 1:
+		c.length = 256 - int(value)
+	case 4:
+		if value&NRx4Trigger != 0 {
+			c.trigger()
+		}
+	}
+}
+
+func (c *waveChannel) trigger() {
+	c.enabled = c.dacEnabled()
+	if c.length == 0 {
+		c.length = 256
+	}
+	c.timer = c.period()
+	c.position = 0
+}
+
+func (c *waveChannel) powerOff() {
+	c.enabled = false
+	c.length = 0
+}
+
+/*
+ * Channel 4 outputs the inverted low bit of a 15-bit linear feedback shift
+ * register, clocked every divisor << shift clocks as set by NR43. In 7-bit
+ * mode the feedback is also copied into bit 6, giving a shorter, more tonal
+ * sequence.
+ */
+type noiseChannel struct {
+	channelBase
+	timer int
+	lfsr  uint16
+}
+
+func newNoiseChannel(mem *GBMem) *noiseChannel {
+	/* NR40 doesn't exist, so the registers start one below NR41 */
+	return &noiseChannel{channelBase: channelBase{mem: mem, base: RegNR41 - 1}}
+}
+
+// period returns the clocks between LFSR shifts, or 0 if it isn't clocked
+func (c *noiseChannel) period() int {
+	NR43 := c.reg(3)
+	shift := uint(NR43 >> 4)
+	if shift >= 14 {
+		return 0
+	}
+	divisor := 8
+	if NR43&0x07 != 0 {
+		divisor = int(NR43&0x07) * 16
+	}
+	return divisor << shift
+}
+
+func (c *noiseChannel) step(cycles int) {
+	period := c.period()
+	if period == 0 {
+		return
+	}
+	c.timer -= cycles
+	for c.timer <= 0 {
+		c.timer += period
+		feedback := (c.lfsr ^ c.lfsr>>1) & 1
+		c.lfsr = c.lfsr>>1 | feedback<<14
+		if c.reg(3)&0x08 != 0 {
+			c.lfsr = c.lfsr&^(1<<6) | feedback<<6
+		}
+	}
+}
+
+func (c *noiseChannel) output() uint8 {
+	if !c.enabled || c.lfsr&1 != 0 {
+		return 0
+	}
+	return c.volume
+}
+
+func (c *noiseChannel) dacEnabled() bool {
+	return c.envelopeDAC()
+}
+
+func (c *noiseChannel) write(reg int, value uint8) {
+	switch reg {
+	case 1:
+		c.length = 64 - int(value&0x3f)
+	case 2:
+		if !c.dacEnabled() {
+			c.enabled = false
+		}
+	case 4:
+		if value&NRx4Trigger != 0 {
+			c.trigger()
+		}
+	}
+}
+
+func (c *noiseChannel) trigger() {
+	c.enabled = c.dacEnabled()
+	if c.length == 0 {
+		c.length = 64
+	}
+	c.timer = c.period()
+	c.lfsr = 0x7fff
+	c.triggerEnvelope()
+}
+
+func (c *noiseChannel) powerOff() {
+	c.enabled = false
+	c.length = 0
+}
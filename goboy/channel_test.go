@@ -0,0 +1,144 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestSquareDuty(t *testing.T) {
+	for duty, want := range [4][8]uint8{
+		{0, 0, 0, 0, 0, 0, 0, 15},
+		{15, 0, 0, 0, 0, 0, 0, 15},
+		{15, 0, 0, 0, 0, 15, 15, 15},
+		{0, 15, 15, 15, 15, 15, 15, 0},
+	} {
+		a := initAPU()
+		playSquare(a, uint8(duty)<<6)
+		var got [8]uint8
+		for i := range got {
+			got[i] = a.ch2.output()
+			a.ch2.step(4)
+		}
+		assert.Equal(t, want, got, "duty %d", duty)
+	}
+}
+
+func TestSquareEnvelope(t *testing.T) {
+	a := initAPU()
+	a.mem.write(RegNR22, 0x2a) // volume 2, increasing every 2 clocks
+	a.mem.write(RegNR24, NRx4Trigger)
+	assert.Equal(t, uint8(2), a.ch2.volume)
+	a.ch2.clockEnvelope()
+	assert.Equal(t, uint8(2), a.ch2.volume)
+	a.ch2.clockEnvelope()
+	assert.Equal(t, uint8(3), a.ch2.volume)
+	for i := 0; i < 40; i++ {
+		a.ch2.clockEnvelope()
+	}
+	assert.Equal(t, uint8(15), a.ch2.volume)
+
+	a.mem.write(RegNR22, 0x31) // volume 3, decreasing every clock
+	a.mem.write(RegNR24, NRx4Trigger)
+	for i := 0; i < 5; i++ {
+		a.ch2.clockEnvelope()
+	}
+	assert.Equal(t, uint8(0), a.ch2.volume)
+
+	// the APU clocks envelopes at 64Hz
+	a.mem.write(RegNR22, 0xf1)
+	a.mem.write(RegNR24, NRx4Trigger)
+	a.step(FrameSequencerPeriod * 8)
+	assert.Equal(t, uint8(14), a.ch2.volume)
+}
+
+func TestSquareSweep(t *testing.T) {
+	a := initAPU()
+	a.mem.write(RegNR10, 0x11) // period 1, increasing, shift 1
+	a.mem.write(RegNR12, 0xf0)
+	a.mem.write(RegNR13, 0x00)
+	a.mem.write(RegNR14, NRx4Trigger|0x02) // 0x200
+	assert.True(t, a.ch1.playing())
+	a.ch1.clockSweep()
+	assert.Equal(t, 0x300, a.ch1.frequency())
+	a.ch1.clockSweep()
+	assert.Equal(t, 0x480, a.ch1.frequency())
+	assert.True(t, a.ch1.playing())
+	// 0x6c0 is written, but the check after it sees 0x6c0 + 0x360 overflow
+	a.ch1.clockSweep()
+	assert.Equal(t, 0x6c0, a.ch1.frequency())
+	assert.False(t, a.ch1.playing())
+
+	// triggering checks for overflow straight away
+	a.mem.write(RegNR10, 0x01)
+	a.mem.write(RegNR14, NRx4Trigger|0x07)
+	assert.False(t, a.ch1.playing())
+
+	// decreasing never overflows
+	a.mem.write(RegNR10, 0x19)
+	a.mem.write(RegNR13, 0xff)
+	a.mem.write(RegNR14, NRx4Trigger|0x07)
+	a.ch1.clockSweep()
+	assert.Equal(t, 0x400, a.ch1.frequency())
+	assert.True(t, a.ch1.playing())
+}
+
+func TestWaveChannel(t *testing.T) {
+	a := initAPU()
+	for i := uint16(0); i < WaveRAMSize; i++ {
+		a.mem.write(WaveRAMStart+i, uint8(i)<<4|0x0f-uint8(i))
+	}
+	a.mem.write(RegNR30, 0x80)
+	a.mem.write(RegNR32, 0x20) // 100%
+	a.mem.write(RegNR33, 0xff)
+	a.mem.write(RegNR34, NRx4Trigger|0x07) // a sample every 2 clocks
+	assert.True(t, a.ch3.playing())
+	for i := 0; i < 32; i++ {
+		want := uint8(i / 2)
+		if i%2 == 1 {
+			want = 0x0f - want
+		}
+		assert.Equal(t, want, a.ch3.output())
+		a.ch3.step(2)
+	}
+
+	a.mem.write(RegNR32, 0x40) // 50%
+	a.mem.write(WaveRAMStart, 0xf0)
+	assert.Equal(t, uint8(7), a.ch3.output())
+	a.mem.write(RegNR32, 0x00)
+	assert.Equal(t, uint8(0), a.ch3.output())
+
+	a.mem.write(RegNR30, 0x00)
+	assert.False(t, a.ch3.playing())
+}
+
+// noisePeriod returns how many LFSR shifts it takes for the output to repeat
+func noisePeriod(a *APU, NR43 uint8) int {
+	a.mem.write(RegNR42, 0xf0)
+	a.mem.write(RegNR43, NR43)
+	a.mem.write(RegNR44, NRx4Trigger)
+	period := a.ch4.period()
+	/* shift out the initial state first */
+	a.ch4.step(period * 15)
+	start := a.ch4.lfsr
+	for i := 1; i < 1<<15; i++ {
+		a.ch4.step(period)
+		if a.ch4.lfsr == start {
+			return i
+		}
+	}
+	return 0
+}
+
+func TestNoiseChannel(t *testing.T) {
+	a := initAPU()
+	assert.Equal(t, 32767, noisePeriod(a, 0x00))
+	assert.Equal(t, 127, noisePeriod(a, 0x08))
+
+	// divisor << shift clocks per shift
+	a.mem.write(RegNR43, 0x00)
+	assert.Equal(t, 8, a.ch4.period())
+	a.mem.write(RegNR43, 0x35)
+	assert.Equal(t, 80<<3, a.ch4.period())
+	a.mem.write(RegNR43, 0xe0)
+	assert.Equal(t, 0, a.ch4.period())
+}
@@ -5,10 +5,12 @@ import (
 	"bytes"
 	"fmt"
 	. "github.com/SrsBusiness/gobjdump"
+	"io"
 	"os"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 )
 
 type FunctionFrame struct {
@@ -20,6 +22,11 @@ type Debugger struct {
 	gb          *GameBoy
 	breakpoints map[uint16]struct{} /* This is how sets work */
 	ROMReader   *bytes.Reader
+	console     io.Writer  /* where the prompt goes */
+	running     sync.Mutex /* held while instructions are being executed */
+	hasRumble   bool
+	rumbleOn    bool /* the cartridge's motor is running */
+	rumbles     int  /* times the motor has been switched on */
 }
 
 func isBreakpoint(m map[uint16]struct{}, breakpoint uint16) bool {
@@ -28,22 +35,24 @@ func isBreakpoint(m map[uint16]struct{}, breakpoint uint16) bool {
 }
 
 func (d *Debugger) pause() {
-	/* Stop TSCLoop */
 	d.gb.Paused = true
 }
 
 func (d *Debugger) resume() {
-	/* restart TSCLoop */
 	d.gb.Paused = false
 }
 
 func (d *Debugger) cont() {
+	d.running.Lock()
+	defer d.running.Unlock()
 	d.resume()
 	d.next()
 	for !isBreakpoint(d.breakpoints, d.gb.get16Reg(PC)) && !d.gb.Paused {
 		d.next()
 	}
 	d.pause()
+	/* stopped by a breakpoint or SIGINT, a good moment to save the game */
+	d.gb.flushSave()
 }
 
 func (d *Debugger) addBreakpoint(addr uint16) {
@@ -55,10 +64,18 @@ func (d *Debugger) deleteBreakpoint(addr uint16) {
 }
 
 func (d *Debugger) next() {
-	d.gb.handleInterrupt()
 	d.gb.Step()
 }
 
+// step executes a single instruction
+func (d *Debugger) step() {
+	d.running.Lock()
+	defer d.running.Unlock()
+	d.resume()
+	d.next()
+	d.pause()
+}
+
 func (d *Debugger) run() {
 	/* TODO: reinitialize to clean state i.e. clear registers, reload ROM, reset memory */
 	d.cont()
@@ -84,37 +101,7 @@ var regNames16 map[string]Reg16ID = map[string]Reg16ID{
 }
 
 func (d *Debugger) printAllRegs() {
-	fmt.Printf(
-		`A:  0x%04x
-B:  0x%04x
-C:  0x%04x
-D:  0x%04x
-E:  0x%04x
-F:  0x%04x
-H:  0x%04x
-L:  0x%04x
-BC: 0x%04x
-DE: 0x%04x
-HL: 0x%04x
-SP: 0x%04x
-PC: 0x%04x
-AF: 0x%04x
-`,
-		d.gb.get8Reg(A),
-		d.gb.get8Reg(B),
-		d.gb.get8Reg(C),
-		d.gb.get8Reg(D),
-		d.gb.get8Reg(E),
-		d.gb.get8Reg(F),
-		d.gb.get8Reg(H),
-		d.gb.get8Reg(L),
-		d.gb.get16Reg(BC),
-		d.gb.get16Reg(DE),
-		d.gb.get16Reg(HL),
-		d.gb.get16Reg(SP),
-		d.gb.get16Reg(PC),
-		d.gb.get16Reg(AF),
-	)
+	d.gb.dumpRegisters(os.Stdout)
 }
 
 func (d *Debugger) print(id string) {
@@ -127,11 +114,32 @@ func (d *Debugger) print(id string) {
 		d.printAllRegs()
 	case "tsc":
 		fmt.Printf("%d\n", d.gb.TSC)
+	case "rumble":
+		d.printRumble()
 	}
 }
 
+func (d *Debugger) rumble(on bool) {
+	d.rumbleOn = on
+	if on {
+		d.rumbles++
+	}
+}
+
+func (d *Debugger) printRumble() {
+	if !d.hasRumble {
+		fmt.Println("no rumble motor")
+		return
+	}
+	state := "off"
+	if d.rumbleOn {
+		state = "on"
+	}
+	fmt.Printf("%s, switched on %d times\n", state, d.rumbles)
+}
+
 func (d *Debugger) prompt() {
-	fmt.Printf(">>> ")
+	fmt.Fprintf(d.console, ">>> ")
 }
 
 func (d *Debugger) printMemory(addr, numBytes uint16) {
@@ -160,18 +168,52 @@ func (d *Debugger) printInstructions(addr, numInstructions uint16) {
 	}
 }
 
+// screenshot handles "screenshot [view] [file]", numbering the file in the
+// current directory if none is given
+func (d *Debugger) screenshot(args []string) {
+	view := ViewScreen
+	if len(args) > 0 {
+		if v, err := parseScreenshotView(strings.ToLower(args[0])); err == nil {
+			view = v
+			args = args[1:]
+		}
+	}
+	var path string
+	if len(args) > 0 {
+		path = args[0]
+	} else {
+		var err error
+		if path, err = nextScreenshotPath("."); err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+	if err := d.gb.saveView(view, path); err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Printf("Saved %s\n", path)
+}
+
 var print_memory_regex = regexp.MustCompile(`^x/([0-9]*)([xi]*)$`)
 
 func debugLoop(d *Debugger) {
 	reader := bufio.NewReader(os.Stdin)
-	for {
-		/* TODO: handle error */
+	for !d.gb.finished() {
 		d.prompt()
-		cmd, _ := reader.ReadString('\n')
+		cmd, err := reader.ReadString('\n')
+		if err == io.EOF && cmd == "" {
+			return
+		}
 		tokens := strings.Fields(strings.ToLower(cmd))
 		if len(tokens) == 0 {
 			continue
 		}
+		if tokens[0] == "screenshot" || tokens[0] == "ss" {
+			/* file names keep their case */
+			d.screenshot(strings.Fields(cmd)[1:])
+			continue
+		}
 		switch len(tokens) {
 		case 1:
 			switch tokens[0] {
@@ -180,9 +222,7 @@ func debugLoop(d *Debugger) {
 			case "c", "continue":
 				d.cont()
 			case "n", "next":
-				d.resume()
-				d.next()
-				d.pause()
+				d.step()
 			case "q", "quit":
 				return
 			}
@@ -213,6 +253,17 @@ func debugLoop(d *Debugger) {
 					break
 				}
 				d.printMemory(uint16(addr), 1)
+			case "press", "release":
+				button, err := parseButton(tokens[1])
+				if err != nil {
+					fmt.Println(err)
+					break
+				}
+				if tokens[0] == "press" {
+					d.gb.joypad.Press(button)
+				} else {
+					d.gb.joypad.Release(button)
+				}
 
 			default:
 				/* boolean switch */
@@ -245,11 +296,14 @@ func debugLoop(d *Debugger) {
 }
 
 func NewDebugger(gb *GameBoy) *Debugger {
-	return &Debugger{
+	d := &Debugger{
 		gb:          gb,
 		breakpoints: make(map[uint16]struct{}),
 		ROMReader:   Gb.mainMemory.cartridge.reader(),
+		console:     os.Stdout,
 	}
+	d.hasRumble = gb.OnRumble(d.rumble)
+	return d
 }
 
 var sig_chan = make(chan os.Signal, 1)
@@ -260,3 +314,21 @@ func (d *Debugger) SIGINTHandler() {
 		d.pause()
 	}
 }
+
+var screenshot_chan = make(chan os.Signal, 1)
+
+// SIGUSR1Handler is the screenshot hotkey: kill -USR1 saves the screen to
+// the next free goboy-NNNN.png once the current frame is drawn, or straight
+// away at the prompt, where no more of it is going to be
+func (d *Debugger) SIGUSR1Handler() {
+	for {
+		<-screenshot_chan
+		d.gb.requestScreenshot()
+		if d.gb.Paused {
+			/* wait for an instruction that is still running */
+			d.running.Lock()
+			d.gb.takeScreenshots()
+			d.running.Unlock()
+		}
+	}
+}
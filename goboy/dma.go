@@ -0,0 +1,53 @@
+package main
+
+const (
+	RegDMA    = 0xff46
+	DMACycles = OAMSize * 4 // one byte per machine cycle
+)
+
+/*
+ * OAM DMA copies 160 bytes from XX00-XX9F to OAM, where XX is the value
+ * written to 0xff46. The copy takes 160 machine cycles, during which the
+ * CPU can only use the internal bus (HRAM and the I/O registers); reads
+ * from anywhere else return 0xff and writes are dropped. Games therefore
+ * start DMA from a small routine copied into HRAM that waits it out.
+ */
+type DMA struct {
+	active bool
+	source uint16
+	copied int // bytes transferred so far
+	cycles int // clocks carried over that don't make up a machine cycle yet
+}
+
+func (m *GBMem) startDMA(value uint8) {
+	m.dma = DMA{
+		active: true,
+		source: uint16(value) << 8,
+	}
+}
+
+// dmaBlocks reports whether the CPU is locked out of addr by a running DMA
+func (m *GBMem) dmaBlocks(addr uint16) bool {
+	return m.dma.active && addr < 0xff00
+}
+
+// stepDMA copies one byte per machine cycle while a transfer is running
+func (m *GBMem) stepDMA(cycles int) {
+	if !m.dma.active {
+		return
+	}
+	m.dma.cycles += cycles
+	for m.dma.cycles >= 4 && m.dma.active {
+		m.dma.cycles -= 4
+		src := m.dma.source + uint16(m.dma.copied)
+		if src >= 0xe000 {
+			/* 0xe0-0xff sources read the WRAM echo on DMG */
+			src -= 0x2000
+		}
+		m.oam[m.dma.copied] = m.readBus(src)
+		m.dma.copied++
+		if m.dma.copied == OAMSize {
+			m.dma.active = false
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestDMATransfer(t *testing.T) {
+	mem := newGBMem(nil)
+	for i := uint16(0); i < OAMSize; i++ {
+		mem.write(0xc100+i, uint8(i)+1)
+	}
+	mem.write(RegDMA, 0xc1)
+	assert.True(t, mem.dma.active)
+
+	mem.stepDMA(4)
+	assert.Equal(t, uint8(1), mem.oam[0])
+	assert.Equal(t, uint8(0), mem.oam[1])
+	mem.stepDMA(DMACycles - 8)
+	assert.True(t, mem.dma.active)
+	assert.Equal(t, uint8(0), mem.oam[OAMSize-1])
+	mem.stepDMA(4)
+	assert.False(t, mem.dma.active)
+	assert.Equal(t, uint8(OAMSize), mem.oam[OAMSize-1])
+}
+
+func TestDMABusRestriction(t *testing.T) {
+	mem := newGBMem(nil)
+	mem.write(0xc000, 0x42)
+	mem.write(0xff85, 0x24)
+	mem.write(RegDMA, 0xc0)
+
+	// only HRAM and I/O are reachable while the transfer runs
+	assert.Equal(t, uint8(0xff), mem.read(0xc000))
+	assert.Equal(t, uint8(0x24), mem.read(0xff85))
+	assert.Equal(t, uint8(0xc0), mem.read(RegDMA))
+	mem.write(0xc000, 0x99)
+	mem.write(0xff86, 0x99)
+
+	mem.stepDMA(DMACycles)
+	assert.Equal(t, uint8(0x42), mem.read(0xc000))
+	assert.Equal(t, uint8(0x99), mem.read(0xff86))
+	assert.Equal(t, uint8(0x42), mem.read(OAMStart))
+}
+
+func TestDMAFromEcho(t *testing.T) {
+	mem := newGBMem(nil)
+	mem.write(0xde00, 0x42)
+	mem.write(RegDMA, 0xfe)
+	mem.stepDMA(DMACycles)
+	assert.Equal(t, uint8(0x42), mem.oam[0])
+}
+
+func TestDMATiming(t *testing.T) {
+	gb := NewGameBoy(newGBROM())
+	gb.RealTime = false
+	gb.mainMemory.write(RegDMA, 0xc0)
+	// spin on jr -2 (12 cycles) in HRAM as ROM is unreachable
+	gb.mainMemory.write(0xff80, 0x18)
+	gb.mainMemory.write(0xff81, 0xfe)
+	gb.set16Reg(PC, 0xff80)
+	for i := 0; i < DMACycles/12; i++ {
+		gb.Step()
+	}
+	assert.True(t, gb.mainMemory.dma.active)
+	gb.Step()
+	assert.False(t, gb.mainMemory.dma.active)
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// initProgram returns a GameBoy executing program from HRAM with IME off
+func initProgram(program ...uint8) *GameBoy {
+	gb := NewGameBoy(newGBROM())
+	gb.RealTime = false
+	gb.interruptEnabled = false
+	for i, b := range program {
+		gb.mainMemory.write(0xff80+uint16(i), b)
+	}
+	gb.set16Reg(PC, 0xff80)
+	gb.set16Reg(SP, 0xfffe)
+	return gb
+}
+
+func TestIERegister(t *testing.T) {
+	mem := &GBMem{}
+	mem.write(RegIE, 0x1f)
+	assert.Equal(t, uint8(0x1f), mem.read(RegIE))
+	assert.Equal(t, uint8(0x1f), mem.ie)
+}
+
+func TestInterruptDispatch(t *testing.T) {
+	gb := initProgram(0x00)
+	gb.interruptEnabled = true
+	gb.mainMemory.write(RegIE, IntTimer|IntVBlank)
+	gb.mainMemory.write(RegIF, IntTimer|IntLCDStat)
+	assert.Equal(t, InterruptDispatchCycles, gb.Step())
+	assert.Equal(t, uint16(0x0050), gb.get16Reg(PC))
+	assert.Equal(t, uint16(0xfffc), gb.get16Reg(SP))
+	assert.Equal(t, uint8(0x80), gb.mainMemory.read(0xfffc))
+	assert.Equal(t, uint8(0xff), gb.mainMemory.read(0xfffd))
+	assert.Equal(t, IntLCDStat, gb.mainMemory.read(RegIF)&0x1f)
+	assert.False(t, gb.interruptEnabled)
+}
+
+func TestEIDelay(t *testing.T) {
+	gb := initProgram(0xfb, 0x00, 0x00) // ei; nop; nop
+	gb.mainMemory.write(RegIE, IntVBlank)
+	gb.mainMemory.write(RegIF, IntVBlank)
+	gb.Step()
+	assert.False(t, gb.interruptEnabled)
+	// the instruction after EI still runs before the interrupt
+	assert.Equal(t, 4, gb.Step())
+	assert.Equal(t, uint16(0xff82), gb.get16Reg(PC))
+	assert.True(t, gb.interruptEnabled)
+	assert.Equal(t, InterruptDispatchCycles, gb.Step())
+	assert.Equal(t, uint16(0x0040), gb.get16Reg(PC))
+}
+
+func TestEIThenDI(t *testing.T) {
+	gb := initProgram(0xfb, 0xf3, 0x00) // ei; di; nop
+	gb.mainMemory.write(RegIE, IntVBlank)
+	gb.mainMemory.write(RegIF, IntVBlank)
+	gb.Step()
+	gb.Step()
+	gb.Step()
+	assert.False(t, gb.interruptEnabled)
+	assert.Equal(t, uint16(0xff83), gb.get16Reg(PC))
+}
+
+func TestRETIEnablesImmediately(t *testing.T) {
+	gb := initProgram(0xd9) // reti
+	gb.mainMemory.write(0xfffc, 0x90)
+	gb.mainMemory.write(0xfffd, 0xff)
+	gb.set16Reg(SP, 0xfffc)
+	gb.mainMemory.write(RegIE, IntSerial)
+	gb.mainMemory.write(RegIF, IntSerial)
+	gb.Step()
+	assert.True(t, gb.interruptEnabled)
+	assert.Equal(t, InterruptDispatchCycles, gb.Step())
+	assert.Equal(t, uint16(0x0058), gb.get16Reg(PC))
+	assert.Equal(t, uint8(0x90), gb.mainMemory.read(0xfffc))
+}
+
+func TestHaltWakeWithoutIME(t *testing.T) {
+	gb := initProgram(0x00)
+	gb.halted = true
+	assert.Equal(t, 4, gb.Step())
+	assert.Equal(t, uint16(0xff80), gb.get16Reg(PC))
+	// requested but not enabled: stays halted
+	gb.mainMemory.write(RegIF, IntJoypad)
+	gb.Step()
+	assert.True(t, gb.halted)
+	// enabled: wakes and carries on without dispatching
+	gb.mainMemory.write(RegIE, IntJoypad)
+	gb.Step()
+	assert.False(t, gb.halted)
+	assert.Equal(t, uint16(0xff81), gb.get16Reg(PC))
+	assert.Equal(t, IntJoypad, gb.mainMemory.read(RegIF)&0x1f)
+}
+
+func TestHaltUntilInterrupt(t *testing.T) {
+	gb := initProgram(0x76, 0x00) // halt; nop
+	gb.interruptEnabled = true
+	gb.mainMemory.write(RegIE, IntVBlank)
+	gb.Step()
+	assert.True(t, gb.halted)
+	// the PPU requests VBlank after 144 lines, until then nothing runs
+	for gb.halted {
+		gb.Step()
+	}
+	assert.True(t, gb.TSC >= visibleHeight*CyclesPerLine)
+	assert.Equal(t, uint16(0x0040), gb.get16Reg(PC))
+	assert.Equal(t, uint8(0x81), gb.mainMemory.read(0xfffc))
+}
+
+func TestHaltBugStep(t *testing.T) {
+	gb := initProgram(0x76, 0x3e, 0x14) // halt; ld a, 0x14
+	gb.mainMemory.write(RegIE, IntTimer)
+	gb.mainMemory.write(RegIF, IntTimer)
+	gb.Step()
+	assert.False(t, gb.halted)
+	// executes as ld a, 0x3e; inc d
+	gb.Step()
+	assert.Equal(t, uint8(0x3e), gb.get8Reg(A))
+	assert.Equal(t, uint16(0xff82), gb.get16Reg(PC))
+	gb.Step()
+	assert.Equal(t, uint8(0x01), gb.get8Reg(D))
+	assert.Equal(t, uint16(0xff83), gb.get16Reg(PC))
+}
+
+func TestHaltBugAfterEI(t *testing.T) {
+	gb := initProgram(0xfb, 0x76, 0x00) // ei; halt; nop
+	gb.mainMemory.write(RegIE, IntVBlank)
+	gb.mainMemory.write(RegIF, IntVBlank)
+	gb.Step()
+	gb.Step()
+	assert.False(t, gb.halted)
+	assert.True(t, gb.interruptEnabled)
+	// the interrupt returns to the HALT, which then halts properly
+	assert.Equal(t, InterruptDispatchCycles, gb.Step())
+	assert.Equal(t, uint16(0x0040), gb.get16Reg(PC))
+	assert.Equal(t, uint8(0x81), gb.mainMemory.read(0xfffc))
+	assert.Equal(t, uint8(0xff), gb.mainMemory.read(0xfffd))
+	assert.False(t, gb.haltBug)
+	// and the handler's first opcode is fetched normally
+	gb.Step()
+	assert.Equal(t, uint16(0x0041), gb.get16Reg(PC))
+}
+
+func TestStopUntilJoypad(t *testing.T) {
+	gb := initProgram(0x10, 0x00, 0x00) // stop; nop
+	gb.Step()
+	assert.True(t, gb.stopped)
+	gb.Step()
+	assert.True(t, gb.stopped)
+	assert.Equal(t, uint16(0xff82), gb.get16Reg(PC))
+	gb.joypad.Press(ButtonStart)
+	gb.Step()
+	assert.False(t, gb.stopped)
+	assert.Equal(t, uint16(0xff83), gb.get16Reg(PC))
+}
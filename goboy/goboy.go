@@ -1,6 +1,8 @@
 package main
 
+import "fmt"
 import "image"
+import "io"
 import "time"
 
 //  Frances was here!
@@ -11,20 +13,121 @@ const (
 	 * 4.194304 MHz. Different for SGB, GBC
 	 * 1 / 4194304Hz * 1000 * 1000 * 1000ns = 238.418ns per clock, 953.674ns per machine clock
 	 */
-	GBClockPeriod = 953
+	GBClockFrequency = 4194304
+	GBClockPeriod    = 953
+
+	CyclesPerLine  = 456                           /* clocks per LCD scanline */
+	LinesPerFrame  = 154                           /* 144 visible + 10 vblank */
+	CyclesPerFrame = CyclesPerLine * LinesPerFrame /* 70224 */
 )
 
+/* ~16.74ms, the wall time one frame should take */
+const FrameDuration = time.Duration(CyclesPerFrame) * time.Second / GBClockFrequency
+
 type GameBoy struct {
-	rom              *GBROM // the ROM object
-	mainMemory       *GBMem // GB main memory
-	*Register               // register state
-	interruptEnabled bool
+	rom              *GBROM      // the ROM object
+	mainMemory       *GBMem      // GB main memory
+	*Register                    // register state
+	interruptEnabled bool        // IME
+	imePending       bool        // EI was just executed, IME is set after the next instruction
+	halted           bool        // HALT: waiting for IE & IF to become non-zero
+	haltBug          bool        // next opcode fetch doesn't increment PC
+	stopped          bool        // STOP: waiting for a joypad press
 	image            *image.RGBA // image to be displayed
-	LCDClock         *time.Ticker
-	CPUClock         *time.Ticker
-	TSC              uint64 /* like TSC on x86 */
-	TSCStart         uint64 /* starting TSC of next instruction */
+	scheduler        *Scheduler  // hardware events keyed on TSC
+	ppu              *PPU
+	timer            *Timer
+	joypad           *Joypad
+	apu              *APU
+	serial           *Serial
+	capture          *SerialCapture
+	audio            AudioSink     /* receives the APU output, resampled */
+	save             *SaveFile     /* nil without battery backed RAM */
+	screenshots      chan struct{} /* screenshots requested during this frame */
+	TSC              uint64        /* like TSC on x86 */
+	frames           uint64        /* frames of emulated time, whether or not the LCD is on */
 	Paused           bool
+	RealTime         bool      /* throttle to wall time at frame boundaries */
+	nextFrame        time.Time /* wall time the current frame should end */
+}
+
+func NewGameBoy(cartridge GBCartridge) *GameBoy {
+	g := &GameBoy{
+		Register:         &Register{},
+		mainMemory:       newGBMem(cartridge),
+		interruptEnabled: true,
+		image:            image.NewRGBA(image.Rect(0, 0, visibleWidth, visibleHeight)),
+		scheduler:        &Scheduler{},
+		screenshots:      make(chan struct{}, 1),
+		Paused:           true,
+		RealTime:         true,
+	}
+	g.ppu = newPPU(g.mainMemory, g.scheduler, g.image)
+	g.ppu.reset(0)
+	g.timer = newTimer(g.mainMemory)
+	g.timer.reset()
+	g.joypad = newJoypad(g.mainMemory)
+	g.joypad.reset()
+	g.apu = newAPU(g.mainMemory)
+	g.apu.reset()
+	g.serial = newSerial(g.mainMemory, g.scheduler)
+	g.scheduler.schedule(EventFrame, CyclesPerFrame, g.frameBoundary)
+	return g
+}
+
+// tick advances the emulated clock and fires any hardware events that are due
+func (g *GameBoy) tick(cycles int) {
+	g.TSC += uint64(cycles)
+	g.mainMemory.stepDMA(cycles)
+	if c, ok := g.mainMemory.cartridge.(clockedCartridge); ok {
+		c.tick(cycles)
+	}
+	if g.timer != nil && !g.stopped {
+		/* STOP halts the oscillator, so DIV doesn't count */
+		g.timer.step(cycles)
+	}
+	if g.apu != nil && !g.stopped {
+		g.apu.step(cycles)
+	}
+	if g.scheduler != nil {
+		g.scheduler.run(g.TSC)
+	}
+}
+
+// OnRumble sets f to be called whenever the cartridge switches its rumble
+// motor on or off. It returns false if the cartridge has no motor.
+func (g *GameBoy) OnRumble(f func(on bool)) bool {
+	r, ok := g.mainMemory.cartridge.(rumbleCartridge)
+	if !ok || !r.hasRumble() {
+		return false
+	}
+	r.onRumble(f)
+	return true
+}
+
+// frameBoundary is the only place emulation is synchronised with wall time
+func (g *GameBoy) frameBoundary(when uint64) {
+	g.scheduler.schedule(EventFrame, when+CyclesPerFrame, g.frameBoundary)
+	g.frames++
+	g.takeScreenshots()
+	if g.RealTime {
+		g.pace()
+	}
+}
+
+// pace sleeps until the wall time the current frame should end. If we are
+// more than a frame behind (e.g. after sitting at a debugger prompt) the
+// schedule is reset rather than trying to catch up.
+func (g *GameBoy) pace() {
+	now := time.Now()
+	if g.nextFrame.IsZero() || now.Sub(g.nextFrame) > FrameDuration {
+		g.nextFrame = now.Add(FrameDuration)
+		return
+	}
+	if wait := g.nextFrame.Sub(now); wait > 0 {
+		time.Sleep(wait)
+	}
+	g.nextFrame = g.nextFrame.Add(FrameDuration)
 }
 
 type Reg8ID int
@@ -141,3 +244,105 @@ func (r *Register) getFlag(flag FlagId) uint8 {
 		return 0
 	}
 }
+
+// attachSaveFile persists the cartridge RAM to s, autosaving every
+// AutosaveInterval clocks of emulated time
+func (g *GameBoy) attachSaveFile(s *SaveFile) {
+	g.save = s
+	g.scheduler.schedule(EventAutosave, g.TSC+AutosaveInterval, g.autosave)
+}
+
+func (g *GameBoy) autosave(when uint64) {
+	g.scheduler.schedule(EventAutosave, when+AutosaveInterval, g.autosave)
+	g.flushSave()
+}
+
+// flushSave writes battery backed RAM out if it has changed
+func (g *GameBoy) flushSave() {
+	if g.save == nil {
+		return
+	}
+	if err := g.save.flush(); err != nil {
+		fmt.Printf("saving %s: %s\n", g.save.path, err)
+	}
+}
+
+// attachAudioSink sends the sound to sink, resampled to rate. If the sink
+// fails, the error is reported and sound is dropped from then on.
+func (g *GameBoy) attachAudioSink(sink AudioSink, rate int) error {
+	resampler, err := newResampler(sink, APUSampleRate, rate)
+	if err != nil {
+		return err
+	}
+	g.audio = resampler
+	g.apu.Output = func(samples []StereoSample) {
+		if err := g.audio.WriteSamples(samples); err != nil {
+			fmt.Printf("audio: %s\n", err)
+			g.apu.Output = nil
+		}
+	}
+	return nil
+}
+
+func (g *GameBoy) closeAudio() {
+	if g.audio == nil {
+		return
+	}
+	g.apu.Output = nil
+	if err := g.audio.Close(); err != nil {
+		fmt.Printf("audio: %s\n", err)
+	}
+	g.audio = nil
+}
+
+// captureSerial copies serial output to c, pausing emulation once it sees
+// its pass or fail string
+func (g *GameBoy) captureSerial(c *SerialCapture) {
+	g.capture = c
+	g.serial.OnTransmit = func(data uint8) {
+		c.write(data)
+		if c.Result != CaptureRunning {
+			g.Paused = true
+		}
+	}
+}
+
+// finished reports whether a serial capture has seen its pass or fail string
+func (g *GameBoy) finished() bool {
+	return g.capture != nil && g.capture.Result != CaptureRunning
+}
+
+// dumpRegisters writes every register, one per line
+func (g *GameBoy) dumpRegisters(w io.Writer) {
+	fmt.Fprintf(w,
+		`A:  0x%04x
+B:  0x%04x
+C:  0x%04x
+D:  0x%04x
+E:  0x%04x
+F:  0x%04x
+H:  0x%04x
+L:  0x%04x
+BC: 0x%04x
+DE: 0x%04x
+HL: 0x%04x
+SP: 0x%04x
+PC: 0x%04x
+AF: 0x%04x
+`,
+		g.get8Reg(A),
+		g.get8Reg(B),
+		g.get8Reg(C),
+		g.get8Reg(D),
+		g.get8Reg(E),
+		g.get8Reg(F),
+		g.get8Reg(H),
+		g.get8Reg(L),
+		g.get16Reg(BC),
+		g.get16Reg(DE),
+		g.get16Reg(HL),
+		g.get16Reg(SP),
+		g.get16Reg(PC),
+		g.get16Reg(AF),
+	)
+}
@@ -5,6 +5,23 @@ import (
 	"testing"
 )
 
+func TestOnRumble(t *testing.T) {
+	rom := makeHeaderROM(0x8000, GBCartridgeMBC5Rumble, 0, 0)
+	cart, err := newCartridge(rom, RTCEmulated)
+	assert.Nil(t, err)
+	gb := NewGameBoy(cart)
+	var events []bool
+	assert.True(t, gb.OnRumble(func(on bool) { events = append(events, on) }))
+	gb.mainMemory.write(0x4000, 0x08)
+	gb.mainMemory.write(0x4000, 0x00)
+	assert.Equal(t, []bool{true, false}, events)
+
+	cart, err = newCartridge(makeHeaderROM(0x8000, GBCartridgeMBC5, 0, 0), RTCEmulated)
+	assert.Nil(t, err)
+	assert.False(t, NewGameBoy(cart).OnRumble(func(bool) {}))
+	assert.False(t, initProgram().OnRumble(func(bool) {}))
+}
+
 func TestGet16Reg(t *testing.T) {
 	regs := &Register{}
 	assert.Equal(t, regs.get16Reg(BC), uint16(0x00))
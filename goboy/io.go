@@ -0,0 +1,118 @@
+package main
+
+/* I/O registers not owned by a more specific subsystem */
+const (
+	RegJOYP = 0xff00 // joypad
+	RegSB   = 0xff01 // serial transfer data
+	RegSC   = 0xff02 // serial transfer control
+	RegDIV  = 0xff04 // divider
+	RegTIMA = 0xff05 // timer counter
+	RegTMA  = 0xff06 // timer modulo
+	RegTAC  = 0xff07 // timer control
+	RegIF   = 0xff0f // interrupt flag
+	RegIE   = 0xffff // interrupt enable
+)
+
+/*
+ * How each register in 0xff00 - 0xff7f looks from the CPU. Bits outside
+ * readable always read as 1, bits outside writable cannot be changed by the
+ * CPU (subsystems still update them through ioregs directly). Addresses not
+ * listed are unmapped: they read 0xff and ignore writes.
+ */
+type ioRegister struct {
+	readable uint8
+	writable uint8
+}
+
+var ioRegisters = map[uint16]ioRegister{
+	RegJOYP: {0x3f, 0x30},
+	RegSB:   {0xff, 0xff},
+	RegSC:   {0x81, 0x81},
+	RegDIV:  {0xff, 0x00},
+	RegTIMA: {0xff, 0xff},
+	RegTMA:  {0xff, 0xff},
+	RegTAC:  {0x07, 0x07},
+	RegIF:   {0x1f, 0x1f},
+	/* sound: frequency low bytes and length counters are write only */
+	RegNR10: {0x7f, 0x7f},
+	RegNR11: {0xc0, 0xff},
+	RegNR12: {0xff, 0xff},
+	RegNR13: {0x00, 0xff},
+	RegNR14: {0x40, 0xc7},
+	RegNR21: {0xc0, 0xff},
+	RegNR22: {0xff, 0xff},
+	RegNR23: {0x00, 0xff},
+	RegNR24: {0x40, 0xc7},
+	RegNR30: {0x80, 0x80},
+	RegNR31: {0x00, 0xff},
+	RegNR32: {0x60, 0x60},
+	RegNR33: {0x00, 0xff},
+	RegNR34: {0x40, 0xc7},
+	RegNR41: {0x00, 0x3f},
+	RegNR42: {0xff, 0xff},
+	RegNR43: {0xff, 0xff},
+	RegNR44: {0x40, 0xc0},
+	RegNR50: {0xff, 0xff},
+	RegNR51: {0xff, 0xff},
+	RegNR52: {0x8f, 0x80},
+	/* LCD */
+	RegLCDC: {0xff, 0xff},
+	RegSTAT: {0x7f, 0x78},
+	RegSCY:  {0xff, 0xff},
+	RegSCX:  {0xff, 0xff},
+	RegLY:   {0xff, 0x00},
+	RegLYC:  {0xff, 0xff},
+	RegDMA:  {0xff, 0xff},
+	RegBGP:  {0xff, 0xff},
+	RegOBP0: {0xff, 0xff},
+	RegOBP1: {0xff, 0xff},
+	RegWY:   {0xff, 0xff},
+	RegWX:   {0xff, 0xff},
+}
+
+func init() {
+	/* wave RAM */
+	for addr := uint16(WaveRAMStart); addr < WaveRAMStart+WaveRAMSize; addr++ {
+		ioRegisters[addr] = ioRegister{0xff, 0xff}
+	}
+}
+
+// ioHook lets a subsystem take part in accesses to one of its registers
+type ioHook struct {
+	/* replaces the stored value on reads when set */
+	read func() uint8
+	/* called after the writable bits have been stored, with the raw value */
+	write func(value uint8)
+}
+
+// hookIO attaches a subsystem to an I/O register. Either function may be nil.
+func (m *GBMem) hookIO(addr uint16, read func() uint8, write func(uint8)) {
+	if m.ioHooks == nil {
+		m.ioHooks = make(map[uint16]ioHook)
+	}
+	m.ioHooks[addr] = ioHook{read: read, write: write}
+}
+
+func (m *GBMem) readIO(addr uint16) uint8 {
+	reg, ok := ioRegisters[addr]
+	if !ok {
+		return 0xff
+	}
+	value := m.ioregs[addr-0xff00]
+	if hook := m.ioHooks[addr]; hook.read != nil {
+		value = hook.read()
+	}
+	return value | ^reg.readable
+}
+
+func (m *GBMem) writeIO(addr uint16, value uint8) {
+	reg, ok := ioRegisters[addr]
+	if !ok {
+		return
+	}
+	old := m.ioregs[addr-0xff00]
+	m.ioregs[addr-0xff00] = old&^reg.writable | value&reg.writable
+	if hook := m.ioHooks[addr]; hook.write != nil {
+		hook.write(value)
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestIOUnusedBits(t *testing.T) {
+	mem := &GBMem{}
+	mem.write(RegTAC, 0x00)
+	assert.Equal(t, uint8(0xf8), mem.read(RegTAC))
+	mem.write(RegIF, 0xff)
+	assert.Equal(t, uint8(0xff), mem.read(RegIF))
+	assert.Equal(t, uint8(0x1f), mem.ioregs[RegIF-0xff00])
+	mem.write(RegIF, 0x00)
+	assert.Equal(t, uint8(0xe0), mem.read(RegIF))
+}
+
+func TestIOUnmapped(t *testing.T) {
+	mem := &GBMem{}
+	mem.write(0xff03, 0x12)
+	assert.Equal(t, uint8(0xff), mem.read(0xff03))
+	assert.Equal(t, uint8(0x00), mem.ioregs[0x03])
+	assert.Equal(t, uint8(0xff), mem.read(0xff7f))
+}
+
+func TestIOReadOnlyBits(t *testing.T) {
+	mem := &GBMem{}
+	mem.ioregs[RegSTAT-0xff00] = 0x03
+	mem.write(RegSTAT, 0x00)
+	assert.Equal(t, uint8(0x83), mem.read(RegSTAT))
+	// write only registers read back as 1s
+	mem.write(0xff13, 0x12)
+	assert.Equal(t, uint8(0xff), mem.read(0xff13))
+	assert.Equal(t, uint8(0x12), mem.ioregs[0x13])
+}
+
+func TestIOHooks(t *testing.T) {
+	mem := &GBMem{}
+	var written []uint8
+	mem.hookIO(RegDIV, func() uint8 { return 0x42 }, func(v uint8) {
+		written = append(written, v)
+	})
+	mem.write(RegDIV, 0x99)
+	assert.Equal(t, []uint8{0x99}, written)
+	assert.Equal(t, uint8(0x00), mem.ioregs[RegDIV-0xff00])
+	assert.Equal(t, uint8(0x42), mem.read(RegDIV))
+}
@@ -0,0 +1,104 @@
+package main
+
+import "fmt"
+
+type Button int
+
+/* Ordered to match the JOYP bit each button pulls low */
+const (
+	ButtonRight Button = iota
+	ButtonLeft
+	ButtonUp
+	ButtonDown
+	ButtonA
+	ButtonB
+	ButtonSelect
+	ButtonStart
+)
+
+var buttonNames = map[string]Button{
+	"right":  ButtonRight,
+	"left":   ButtonLeft,
+	"up":     ButtonUp,
+	"down":   ButtonDown,
+	"a":      ButtonA,
+	"b":      ButtonB,
+	"select": ButtonSelect,
+	"start":  ButtonStart,
+}
+
+func parseButton(name string) (Button, error) {
+	if b, ok := buttonNames[name]; ok {
+		return b, nil
+	}
+	return 0, fmt.Errorf("unknown button %q", name)
+}
+
+/* JOYP select lines, a 0 selects the group */
+const (
+	JOYPSelectDirections = 0x10 // P14
+	JOYPSelectActions    = 0x20 // P15
+)
+
+/*
+ * The buttons form a 2x4 matrix. Writing 0 to P14 and/or P15 selects the
+ * direction and/or action keys, and a held button in a selected group
+ * pulls its bit of P10 - P13 low. Any of those lines falling requests the
+ * joypad interrupt, whether from a press or from selecting a group in which
+ * a button is already held.
+ *
+ * Press and Release must be called from the goroutine running the emulator.
+ */
+type Joypad struct {
+	mem     *GBMem
+	pressed uint8 /* one bit per Button, 1 while held */
+}
+
+func newJoypad(mem *GBMem) *Joypad {
+	j := &Joypad{mem: mem}
+	mem.hookIO(RegJOYP, nil, func(uint8) { j.update() })
+	return j
+}
+
+// reset leaves both groups selected, as the boot ROM does
+func (j *Joypad) reset() {
+	j.pressed = 0
+	j.mem.ioregs[RegJOYP-0xff00] = 0xcf
+}
+
+func (j *Joypad) Press(b Button) {
+	j.pressed |= 1 << uint(b)
+	j.update()
+}
+
+func (j *Joypad) Release(b Button) {
+	j.pressed &^= 1 << uint(b)
+	j.update()
+}
+
+func (j *Joypad) isPressed(b Button) bool {
+	return j.pressed&(1<<uint(b)) != 0
+}
+
+// lines returns P10 - P13 for the selected groups, active low
+func (j *Joypad) lines(selects uint8) uint8 {
+	var low uint8
+	if selects&JOYPSelectDirections == 0 {
+		low |= j.pressed & 0x0f
+	}
+	if selects&JOYPSelectActions == 0 {
+		low |= j.pressed >> 4
+	}
+	return ^low & 0x0f
+}
+
+// update drives the input lines into JOYP, interrupting on a falling edge
+func (j *Joypad) update() {
+	reg := &j.mem.ioregs[RegJOYP-0xff00]
+	old := *reg & 0x0f
+	lines := j.lines(*reg)
+	if old&^lines != 0 {
+		j.mem.requestInterrupt(IntJoypad)
+	}
+	*reg = *reg&^0x0f | lines
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func initJoypad() *Joypad {
+	j := newJoypad(&GBMem{})
+	j.reset()
+	return j
+}
+
+func TestJoypadSelect(t *testing.T) {
+	j := initJoypad()
+	j.mem.write(RegJOYP, 0x30)
+	assert.Equal(t, uint8(0xff), j.mem.read(RegJOYP))
+	j.Press(ButtonDown)
+	j.Press(ButtonA)
+	// nothing selected, nothing reads
+	assert.Equal(t, uint8(0xff), j.mem.read(RegJOYP))
+	j.mem.write(RegJOYP, JOYPSelectActions)
+	assert.Equal(t, uint8(0xe7), j.mem.read(RegJOYP))
+	j.mem.write(RegJOYP, JOYPSelectDirections)
+	assert.Equal(t, uint8(0xde), j.mem.read(RegJOYP))
+	j.mem.write(RegJOYP, 0x00)
+	assert.Equal(t, uint8(0xc6), j.mem.read(RegJOYP))
+	j.Release(ButtonDown)
+	assert.Equal(t, uint8(0xce), j.mem.read(RegJOYP))
+	// the input lines aren't writable
+	j.mem.write(RegJOYP, 0x0f)
+	assert.Equal(t, uint8(0xce), j.mem.read(RegJOYP))
+}
+
+func TestJoypadInterrupt(t *testing.T) {
+	j := initJoypad()
+	j.mem.write(RegJOYP, JOYPSelectActions)
+	// buttons in the other group don't pull a line low
+	j.Press(ButtonStart)
+	assert.Equal(t, uint8(0), j.mem.ioregs[RegIF-0xff00]&IntJoypad)
+	j.Press(ButtonLeft)
+	assert.Equal(t, IntJoypad, j.mem.ioregs[RegIF-0xff00]&IntJoypad)
+
+	// releasing is a rising edge
+	j.mem.ioregs[RegIF-0xff00] = 0
+	j.Release(ButtonLeft)
+	assert.Equal(t, uint8(0), j.mem.ioregs[RegIF-0xff00]&IntJoypad)
+
+	// selecting a group with a button held is a falling edge too
+	j.mem.write(RegJOYP, JOYPSelectDirections)
+	assert.Equal(t, IntJoypad, j.mem.ioregs[RegIF-0xff00]&IntJoypad)
+}
+
+func TestParseButton(t *testing.T) {
+	b, err := parseButton("select")
+	assert.Nil(t, err)
+	assert.Equal(t, ButtonSelect, b)
+	_, err = parseButton("turbo")
+	assert.EqualError(t, err, `unknown button "turbo"`)
+}
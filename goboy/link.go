@@ -0,0 +1,178 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+/*
+ * Messages on the link socket are a kind byte, the sequence number of the
+ * transfer and the data byte. A reply carries the number of the transfer it
+ * answers so that one arriving after its transfer timed out is ignored.
+ */
+const (
+	linkTransfer = 0x01 // clocked out by the side with the internal clock
+	linkReply    = 0x02 // what the externally clocked side shifted back
+)
+
+/*
+ * How long the clocking side waits for the other end to answer a byte.
+ * On timeout it reads 0xff, like a cable with nothing on the other end.
+ */
+const LinkTimeout = time.Second
+
+var errLinkClosed = errors.New("disconnected")
+
+type linkMessage struct {
+	seq  uint8
+	data uint8
+}
+
+/*
+ * Link connects the serial ports of two goboy processes over a TCP or Unix
+ * socket. Bits aren't sent one at a time: the side driving the clock sends
+ * a whole byte when its transfer starts, and the other side swaps it for
+ * the contents of its SB as soon as it is ready to receive.
+ */
+type Link struct {
+	conn      net.Conn
+	transfers chan linkMessage /* the other side's transfers */
+	replies   chan linkMessage /* answers to our transfers */
+	sent      uint8            /* sequence number of our last transfer */
+	deadline  time.Time        /* when our last transfer times out */
+	received  uint8            /* sequence number of the last transfer polled */
+}
+
+// parseLinkAddress splits "unix:/path" or "[tcp:]host:port"
+func parseLinkAddress(addr string) (network, address string) {
+	if strings.HasPrefix(addr, "unix:") {
+		return "unix", strings.TrimPrefix(addr, "unix:")
+	}
+	return "tcp", strings.TrimPrefix(addr, "tcp:")
+}
+
+// listenLink waits for the other goboy to connect to addr
+func listenLink(addr string) (*Link, error) {
+	network, address := parseLinkAddress(addr)
+	l, err := net.Listen(network, address)
+	if err != nil {
+		return nil, err
+	}
+	defer l.Close()
+	conn, err := l.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return newLink(conn), nil
+}
+
+func dialLink(addr string) (*Link, error) {
+	network, address := parseLinkAddress(addr)
+	conn, err := net.Dial(network, address)
+	if err != nil {
+		return nil, err
+	}
+	return newLink(conn), nil
+}
+
+func newLink(conn net.Conn) *Link {
+	l := &Link{
+		conn:      conn,
+		transfers: make(chan linkMessage, 16),
+		replies:   make(chan linkMessage, 16),
+	}
+	go l.readLoop()
+	return l
+}
+
+// readLoop sorts incoming messages until the connection drops. It never
+// blocks on a full channel, so a backlog of transfers nobody on this side
+// is receiving can't hold up the replies behind it.
+func (l *Link) readLoop() {
+	defer close(l.transfers)
+	defer close(l.replies)
+	buf := make([]uint8, 3)
+	for {
+		if _, err := io.ReadFull(l.conn, buf); err != nil {
+			return
+		}
+		msg := linkMessage{seq: buf[1], data: buf[2]}
+		switch buf[0] {
+		case linkTransfer:
+			select {
+			case l.transfers <- msg:
+			default:
+				/* dropped, the other side times out as if nobody was there */
+			}
+		case linkReply:
+			select {
+			case l.replies <- msg:
+			default:
+			}
+		}
+	}
+}
+
+func (l *Link) send(kind, seq, data uint8) {
+	if _, err := l.conn.Write([]uint8{kind, seq, data}); err != nil {
+		fmt.Fprintf(os.Stderr, "link: %s\n", err)
+	}
+}
+
+// transfer clocks a byte out to the other side
+func (l *Link) transfer(data uint8) {
+	l.sent++
+	l.deadline = time.Now().Add(LinkTimeout)
+	l.send(linkTransfer, l.sent, data)
+}
+
+// answer completes the last transfer the other side clocked
+func (l *Link) answer(data uint8) {
+	l.send(linkReply, l.received, data)
+}
+
+// reply returns the answer to the last transfer once it has arrived, or
+// 0xff once it has timed out. It never waits: ok is false while neither
+// has happened yet.
+func (l *Link) reply() (data uint8, ok bool, err error) {
+	for {
+		select {
+		case msg, open := <-l.replies:
+			if !open {
+				return 0xff, true, errLinkClosed
+			}
+			if msg.seq != l.sent {
+				continue /* the answer to a transfer that timed out */
+			}
+			return msg.data, true, nil
+		default:
+			if time.Now().After(l.deadline) {
+				return 0xff, true, nil
+			}
+			return 0, false, nil
+		}
+	}
+}
+
+// poll returns a byte clocked in by the other side, if there is one
+func (l *Link) poll() (data uint8, ok bool, err error) {
+	select {
+	case msg, open := <-l.transfers:
+		if !open {
+			return 0, false, errLinkClosed
+		}
+		l.received = msg.seq
+		return msg.data, true, nil
+	default:
+		return 0, false, nil
+	}
+}
+
+func (l *Link) Close() error {
+	return l.conn.Close()
+}
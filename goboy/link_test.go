@@ -0,0 +1,164 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestParseLinkAddress(t *testing.T) {
+	network, addr := parseLinkAddress("unix:/tmp/goboy.sock")
+	assert.Equal(t, "unix", network)
+	assert.Equal(t, "/tmp/goboy.sock", addr)
+	network, addr = parseLinkAddress("tcp:localhost:5000")
+	assert.Equal(t, "tcp", network)
+	assert.Equal(t, "localhost:5000", addr)
+	network, addr = parseLinkAddress("localhost:5000")
+	assert.Equal(t, "tcp", network)
+	assert.Equal(t, "localhost:5000", addr)
+}
+
+func TestLinkTransfer(t *testing.T) {
+	a, b := net.Pipe()
+	master, slave := initSerial(), initSerial()
+	master.connect(newLink(a))
+	slave.connect(newLink(b))
+	defer master.link.Close()
+	defer slave.link.Close()
+
+	slave.mem.write(RegSB, 0x42)
+	slave.mem.write(RegSC, SCTransfer)
+	master.mem.write(RegSB, 0x99)
+	master.mem.write(RegSC, SCTransfer|SCInternalClock)
+
+	// the slave finishes once the master's byte arrives
+	deadline := time.Now().Add(LinkTimeout)
+	for slave.mem.read(RegSC)&SCTransfer != 0 && time.Now().Before(deadline) {
+		runSerial(slave, SerialBitCycles)
+	}
+	assert.Equal(t, uint8(0x99), slave.mem.read(RegSB))
+	assert.Equal(t, IntSerial, slave.mem.ioregs[RegIF-0xff00]&IntSerial)
+
+	// the master's clock waits for the answer, then shifts it in
+	for master.mem.read(RegSC)&SCTransfer != 0 && time.Now().Before(deadline) {
+		runSerial(master, SerialBitCycles)
+	}
+	assert.Equal(t, uint8(0x42), master.mem.read(RegSB))
+	assert.Equal(t, uint8(0), master.mem.read(RegSC)&SCTransfer)
+	assert.Equal(t, IntSerial, master.mem.ioregs[RegIF-0xff00]&IntSerial)
+}
+
+func TestLinkSocket(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	addr := l.Addr().String()
+	l.Close()
+
+	listened := make(chan *Link)
+	go func() {
+		link, err := listenLink("tcp:" + addr)
+		assert.Nil(t, err)
+		listened <- link
+	}()
+	var dialed *Link
+	for i := 0; i < 100 && dialed == nil; i++ {
+		dialed, err = dialLink(addr)
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Nil(t, err)
+	server := <-listened
+	defer server.Close()
+	defer dialed.Close()
+
+	dialed.transfer(0x12)
+	data, ok, err := server.poll()
+	for !ok {
+		data, ok, err = server.poll()
+	}
+	assert.Nil(t, err)
+	assert.Equal(t, uint8(0x12), data)
+	server.answer(0x34)
+	data, ok, err = dialed.reply()
+	for !ok {
+		data, ok, err = dialed.reply()
+	}
+	assert.Nil(t, err)
+	assert.Equal(t, uint8(0x34), data)
+}
+
+// waitReply polls for the answer to the last transfer
+func waitReply(l *Link) (uint8, error) {
+	data, ok, err := l.reply()
+	for !ok {
+		time.Sleep(time.Millisecond)
+		data, ok, err = l.reply()
+	}
+	return data, err
+}
+
+func TestLinkStaleReply(t *testing.T) {
+	a, b := net.Pipe()
+	master, slave := newLink(a), newLink(b)
+	defer master.Close()
+	defer slave.Close()
+
+	master.transfer(0x01)
+	master.deadline = time.Now()
+	data, err := waitReply(master)
+	assert.Nil(t, err)
+	assert.Equal(t, uint8(0xff), data)
+
+	// the late answer to the first transfer doesn't answer the second
+	for _, ok, _ := slave.poll(); !ok; _, ok, _ = slave.poll() {
+	}
+	slave.answer(0xaa)
+	master.transfer(0x02)
+	for _, ok, _ := slave.poll(); !ok; _, ok, _ = slave.poll() {
+	}
+	slave.answer(0xbb)
+	data, err = waitReply(master)
+	assert.Nil(t, err)
+	assert.Equal(t, uint8(0xbb), data)
+}
+
+func TestLinkBacklog(t *testing.T) {
+	a, b := net.Pipe()
+	master, slave := newLink(a), newLink(b)
+	defer master.Close()
+	defer slave.Close()
+
+	// the master side never polls its own transfers
+	for i := 0; i < 64; i++ {
+		slave.transfer(uint8(i))
+	}
+	master.transfer(0x01)
+	for _, ok, _ := slave.poll(); !ok; _, ok, _ = slave.poll() {
+	}
+	slave.answer(0x55)
+	data, err := waitReply(master)
+	assert.Nil(t, err)
+	assert.Equal(t, uint8(0x55), data)
+}
+
+func TestLinkDisconnect(t *testing.T) {
+	a, b := net.Pipe()
+	s := initSerial()
+	s.connect(newLink(a))
+	b.Close()
+
+	s.mem.write(RegSC, SCTransfer)
+	deadline := time.Now().Add(LinkTimeout)
+	for s.link != nil && time.Now().Before(deadline) {
+		runSerial(s, SerialBitCycles)
+	}
+	assert.Nil(t, s.link)
+	_, scheduled := s.scheduler.next()
+	assert.False(t, scheduled)
+
+	// transfers carry on as if nothing was connected
+	s.mem.write(RegSB, 0x00)
+	s.mem.write(RegSC, SCTransfer|SCInternalClock)
+	runSerial(s, SerialBitCycles*8)
+	assert.Equal(t, uint8(0xff), s.mem.read(RegSB))
+}
@@ -3,13 +3,14 @@ package main
 import (
 	"flag"
 	"fmt"
-	"image"
+	"github.com/mukkid/GoBoy/gbheader"
+	"io"
+	"os"
 	"os/signal"
 	"syscall"
-	"time"
 )
 
-// 256x256 is written to in total but only 160x144 is visible.
+// The background map is 256x256 but only a 160x144 window of it is visible.
 const (
 	screenWidth   = 256
 	screenHeight  = 256
@@ -20,41 +21,202 @@ const (
 // global emulation state
 var Gb *GameBoy
 
-func main() {
-	// init gameboy
-	Gb = &GameBoy{
-		Register:         &Register{},
-		mainMemory:       &GBMem{cartridge: &GBROM{}},
-		interruptEnabled: true,
-		image:            image.NewRGBA(image.Rect(0, 0, screenWidth, screenHeight)),
-		LCDClock:         time.NewTicker(108 * time.Microsecond),
-		CPUClock:         time.NewTicker(GBClockPeriod),
-		TSC:              0,
-		TSCStart:         0,
-		Paused:           true,
-	}
-
-	// load rom from file
-	rom_path := flag.String("rom", "", "rom image to load")
-	flag.Parse()
-	if *rom_path != "" {
-		Gb.mainMemory.cartridge.loadROMFromFile(*rom_path)
-		fmt.Printf("Loaded %s\n", *rom_path)
+/* Flags shared by the debugger and the headless runner */
+type options struct {
+	rom         string
+	palette     string
+	rtc         string
+	audioOut    string
+	audioRate   int
+	linkListen  string
+	linkConnect string
+	serialOut   string
+	serialPass  string
+	serialFail  string
+	saveDir     string
+	headless    bool /* goboy run: stdout is only for serial output */
+}
+
+func (o *options) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.rom, "rom", "", "rom image to load")
+	fs.StringVar(&o.palette, "palette", DefaultColorScheme,
+		"colour scheme: dmg, grayscale, pocket, or a file of four #rrggbb colours")
+	fs.StringVar(&o.rtc, "rtc", "wall", "MBC3 clock source: wall or emulated")
+	fs.StringVar(&o.audioOut, "audio-out", "", "record sound to this .wav file, or null to discard it")
+	fs.IntVar(&o.audioRate, "audio-rate", DefaultAudioRate, "sample rate of recorded sound")
+	fs.StringVar(&o.linkListen, "link-listen", "", "wait for a link cable on [tcp:]host:port or unix:path")
+	fs.StringVar(&o.linkConnect, "link-connect", "", "connect a link cable to [tcp:]host:port or unix:path")
+	fs.StringVar(&o.serialOut, "serial-out", "", "copy serial output to this file, - for stdout")
+	fs.StringVar(&o.serialPass, "serial-pass", "", "run without the prompt, exiting with status 0 once the serial output contains this")
+	fs.StringVar(&o.serialFail, "serial-fail", "", "run without the prompt, exiting with status 1 once the serial output contains this")
+	fs.StringVar(&o.saveDir, "save-dir", "", "directory for .sav files (default: next to the rom)")
+}
+
+// capturing reports whether serial output is being captured
+func (o *options) capturing() bool {
+	return o.serialOut != "" || o.serialPass != "" || o.serialFail != ""
+}
+
+// console is where messages for the user go. While capturing, or running
+// headless, that's stderr, keeping stdout for the serial output.
+func (o *options) console() io.Writer {
+	if o.capturing() || o.headless {
+		return os.Stderr
+	}
+	return os.Stdout
+}
+
+// setup builds a GameBoy as the options describe. The returned function
+// saves the game and releases files and connections once emulation is over.
+func setup(o *options) (*GameBoy, func(), error) {
+	scheme, err := loadColorScheme(o.palette)
+	if err != nil {
+		return nil, nil, err
+	}
+	rtcMode, err := parseRTCMode(o.rtc)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	console := o.console()
+	var closers []io.Closer
+	cleanup := func() {
+		for _, c := range closers {
+			c.Close()
+		}
+	}
+	fail := func(err error) (*GameBoy, func(), error) {
+		cleanup()
+		return nil, nil, err
+	}
+
+	// load rom from file, picking the cartridge type from its header
+	var cartridge GBCartridge = &GBROM{}
+	var save *SaveFile
+	if o.rom != "" {
+		var header *gbheader.Header
+		cartridge, header, err = loadCartridge(o.rom, rtcMode)
+		if err != nil {
+			return fail(fmt.Errorf("%s: %s", o.rom, err))
+		}
+		fmt.Fprintf(console, "Loaded %s: %q, %s\n", o.rom, header.Title, GBCartridgeType(header.CartridgeType))
+		/* real hardware would lock up, but there's no harm in trying */
+		if err := header.Validate(); err != nil {
+			fmt.Fprintf(console, "warning: %s\n", err)
+		}
+		if !header.GlobalChecksumValid() {
+			fmt.Fprintf(console, "warning: global checksum is 0x%04x, expected 0x%04x\n",
+				header.GlobalChecksum, header.ComputedGlobalChecksum)
+		}
+		if b, ok := cartridge.(batteryCartridge); ok && GBCartridgeType(header.CartridgeType).hasBattery() {
+			path := saveFilePath(o.rom, o.saveDir)
+			save, err = openSaveFile(path, b)
+			if err != nil {
+				return fail(fmt.Errorf("%s: %s", path, err))
+			}
+		}
 	}
 
-	// Initialize joypad values
-	Gb.mainMemory.ioregs[0] = 0xff
+	// init gameboy
+	g := NewGameBoy(cartridge)
+	g.ppu.scheme = scheme
+	if save != nil {
+		g.attachSaveFile(save)
+	}
+	if o.linkListen != "" || o.linkConnect != "" {
+		var link *Link
+		if o.linkListen != "" {
+			fmt.Fprintf(console, "Waiting for link cable on %s\n", o.linkListen)
+			link, err = listenLink(o.linkListen)
+		} else {
+			link, err = dialLink(o.linkConnect)
+		}
+		if err != nil {
+			return fail(fmt.Errorf("link: %s", err))
+		}
+		closers = append(closers, link)
+		g.serial.connect(link)
+	}
+	if o.capturing() {
+		var out io.Writer
+		switch o.serialOut {
+		case "":
+		case "-":
+			out = os.Stdout
+		default:
+			f, err := os.Create(o.serialOut)
+			if err != nil {
+				return fail(err)
+			}
+			closers = append(closers, f)
+			out = f
+		}
+		g.captureSerial(newSerialCapture(out, o.serialPass, o.serialFail))
+	}
+	if o.audioOut != "" {
+		/* without a sink the APU output isn't resampled at all */
+		sink, err := openAudioSink(o.audioOut, o.audioRate)
+		if err != nil {
+			return fail(err)
+		}
+		if err := g.attachAudioSink(sink, o.audioRate); err != nil {
+			sink.Close()
+			return fail(err)
+		}
+	}
 
 	/* Initialize PC to 0x100 */
-	Gb.set16Reg(PC, 0x100)
+	g.set16Reg(PC, 0x100)
+
+	return g, func() {
+		g.flushSave()
+		g.closeAudio()
+		cleanup()
+	}, nil
+}
+
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "run" {
+		os.Exit(runMain(os.Args[2:]))
+	}
+
+	var o options
+	o.register(flag.CommandLine)
+	flag.Parse()
+	var err error
+	var cleanup func()
+	Gb, cleanup, err = setup(&o)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
 
 	d := NewDebugger(Gb)
+	d.console = o.console()
 	/* Initialize SIGINT handler */
 	go d.SIGINTHandler()
 	signal.Notify(sig_chan, syscall.SIGINT)
+	go d.SIGUSR1Handler()
+	signal.Notify(screenshot_chan, syscall.SIGUSR1)
 
-	go Gb.LCDLoop()
-	go Gb.TSCLoop()
-
+	if Gb.capture != nil && Gb.capture.watching() {
+		/*
+		 * Watching for a result is for scripts, which want an exit status
+		 * rather than a prompt: run straight away, and exit as goboy run
+		 * would once the result is in or Ctrl-C stops it.
+		 */
+		d.cont()
+		cleanup()
+		if !Gb.finished() {
+			os.Exit(ExitInterrupted)
+		}
+		os.Exit(Gb.capture.exitStatus())
+	}
 	debugLoop(d)
+	cleanup()
+	if Gb.capture != nil {
+		if status := Gb.capture.exitStatus(); status != 0 {
+			os.Exit(status)
+		}
+	}
 }
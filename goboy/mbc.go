@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+)
+
+const (
+	ROMBankSize = 0x4000 // 16KB, mapped at 0x0000 and 0x4000
+	RAMBankSize = 0x2000 // 8KB, mapped at 0xa000
+)
+
+/*
+ * ROM storage shared by the banked cartridge types. Embedding it provides
+ * the loading half of GBCartridge.
+ */
+type bankedROM struct {
+	rom []uint8
+}
+
+// loadROM keeps a copy of data padded to a whole number of banks, at least two
+func (b *bankedROM) loadROM(data []uint8) error {
+	size := 2 * ROMBankSize
+	for size < len(data) {
+		size *= 2
+	}
+	b.rom = make([]uint8, size)
+	copy(b.rom, data)
+	return nil
+}
+
+func (b *bankedROM) loadROMFromFile(fname string) error {
+	data, err := ioutil.ReadFile(fname)
+	if err != nil {
+		return err
+	}
+	return b.loadROM(data)
+}
+
+func (b *bankedROM) reader() *bytes.Reader {
+	return bytes.NewReader(b.rom)
+}
+
+// readROMBank reads addr within the given 16KB bank. Bank numbers wrap
+// around the size of the ROM, as the unused upper bits aren't wired up.
+func (b *bankedROM) readROMBank(bank int, addr uint16) uint8 {
+	if len(b.rom) == 0 {
+		return 0xff
+	}
+	banks := len(b.rom) / ROMBankSize
+	return b.rom[(bank%banks)*ROMBankSize+int(addr&(ROMBankSize-1))]
+}
+
+/* External cartridge RAM in 8KB banks at 0xa000 - 0xbfff */
+type bankedRAM struct {
+	ram   []uint8
+	dirty bool /* written since the last saveRAM */
+}
+
+func newBankedRAM(size int) bankedRAM {
+	return bankedRAM{ram: make([]uint8, size)}
+}
+
+func (b *bankedRAM) ramOffset(bank int, addr uint16) int {
+	banks := (len(b.ram) + RAMBankSize - 1) / RAMBankSize
+	offset := (bank%banks)*RAMBankSize + int(addr&(RAMBankSize-1))
+	/* 2KB RAM chips are mirrored across the whole bank */
+	return offset % len(b.ram)
+}
+
+func (b *bankedRAM) readRAMBank(bank int, addr uint16) uint8 {
+	if len(b.ram) == 0 {
+		return 0xff
+	}
+	return b.ram[b.ramOffset(bank, addr)]
+}
+
+func (b *bankedRAM) writeRAMBank(bank int, addr uint16, data uint8) {
+	if len(b.ram) == 0 {
+		return
+	}
+	offset := b.ramOffset(bank, addr)
+	if b.ram[offset] != data {
+		b.ram[offset] = data
+		b.dirty = true
+	}
+}
+
+func (b *bankedRAM) saveRAM() []uint8 {
+	b.dirty = false
+	return append([]uint8(nil), b.ram...)
+}
+
+// loadRAM restores a save file. Files of the wrong size are loaded as far
+// as they go, as some emulators pad or truncate them.
+func (b *bankedRAM) loadRAM(data []uint8) error {
+	copy(b.ram, data)
+	b.dirty = false
+	return nil
+}
+
+func (b *bankedRAM) ramDirty() bool {
+	return b.dirty
+}
@@ -6,53 +6,65 @@ type GBMem struct {
 	/* Work RAM at 0xc000 - 0xd000 */
 	wram [8 * 1024]uint8
 	vram [8 * 1024]uint8
+	/* OAM: 0xfe00 - 0xfe9f, 40 sprites of 4 bytes */
+	oam [0xa0]uint8
 	/* HRAM: 0xff80 - 0xfffe */
 	hram   [127]uint8
-	ioregs [127]uint8
+	ioregs [0x80]uint8
+	/* IE: 0xffff */
+	ie uint8
 	/* ROM bank 0, nonswitchable - I believe this means this bank is static */
 	cartridge GBCartridge
+	dma       DMA
+	ioHooks   map[uint16]ioHook
+}
+
+func newGBMem(cartridge GBCartridge) *GBMem {
+	m := &GBMem{cartridge: cartridge}
+	m.hookIO(RegDMA, nil, m.startDMA)
+	return m
 }
 
 /* Cartridge type specified at 0x0147 */
 type GBCartridgeType uint8
 
 const (
-	GBCartridgeROM GBCartridgeType = 0x00
-	GBCartridgeMBC1
-	GBCartridgeMBC1RAM
-	GBCartridgeMBC1RAMBattery
+	GBCartridgeROM            GBCartridgeType = 0x00
+	GBCartridgeMBC1           GBCartridgeType = 0x01
+	GBCartridgeMBC1RAM        GBCartridgeType = 0x02
+	GBCartridgeMBC1RAMBattery GBCartridgeType = 0x03
 	/* 0x04 unused */
-	GBCartridgeMBC2 = 0x05
-	GBCartridgeMBC2RAMBattery
+	GBCartridgeMBC2           GBCartridgeType = 0x05
+	GBCartridgeMBC2RAMBattery GBCartridgeType = 0x06
 	/* 0x07 unused */
-	GBCartridgeROMRAM = 0x08
-	GBCartridgeROMRAMBattery
+	GBCartridgeROMRAM        GBCartridgeType = 0x08
+	GBCartridgeROMRAMBattery GBCartridgeType = 0x09
 	/* 0x0a unused */
-	GBCartridgeMMM01 = 0x0b
-	GBCartridgeMMM01RAM
-	GBCartridgeMMM01RAMBattery
+	GBCartridgeMMM01           GBCartridgeType = 0x0b
+	GBCartridgeMMM01RAM        GBCartridgeType = 0x0c
+	GBCartridgeMMM01RAMBattery GBCartridgeType = 0x0d
 	/* 0x0e unused */
-	GBCartridgeMBC3TimerBattery = 0x0f
-	GBCartridgeMBC3RAMTimerBattery
-	GBCartridgeMBC3
-	GBCartridgeMBC3RAM
-	GBCartridgeMBC3RAMBattery
+	GBCartridgeMBC3TimerBattery    GBCartridgeType = 0x0f
+	GBCartridgeMBC3RAMTimerBattery GBCartridgeType = 0x10
+	GBCartridgeMBC3                GBCartridgeType = 0x11
+	GBCartridgeMBC3RAM             GBCartridgeType = 0x12
+	GBCartridgeMBC3RAMBattery      GBCartridgeType = 0x13
 	/* 0x14 - 0x18 unused */
-	GBCartridgeMBC5 = 0x19
-	GBCartridgeMBC5RAM
-	GBCartridgeMBC5RAMBattery
-	GBCartridgeMBC5Rumble
-	GBCartridgeMBC5RAMRumble
-	GBCartridgeMBC5RAMBatteryRumble
+	GBCartridgeMBC5                 GBCartridgeType = 0x19
+	GBCartridgeMBC5RAM              GBCartridgeType = 0x1a
+	GBCartridgeMBC5RAMBattery       GBCartridgeType = 0x1b
+	GBCartridgeMBC5Rumble           GBCartridgeType = 0x1c
+	GBCartridgeMBC5RAMRumble        GBCartridgeType = 0x1d
+	GBCartridgeMBC5RAMBatteryRumble GBCartridgeType = 0x1e
 	/* 0x1f unused */
-	GBCartridgeMBC6RAMBattery = 0x20
+	GBCartridgeMBC6RAMBattery GBCartridgeType = 0x20
 	/* 0x21 unused */
-	GBCartridgeMBC7RAMBatAccel = 0x22
+	GBCartridgeMBC7RAMBatAccel GBCartridgeType = 0x22
 	/* 0x23 - 0xfb unused */
-	GBCartridgePocketCamera = 0xfc
-	GBCartridgeBandaiTAMA5
-	GBCartridgeHuC3
-	GBCartridgeHuC1RAMBattery
+	GBCartridgePocketCamera   GBCartridgeType = 0xfc
+	GBCartridgeBandaiTAMA5    GBCartridgeType = 0xfd
+	GBCartridgeHuC3           GBCartridgeType = 0xfe
+	GBCartridgeHuC1RAMBattery GBCartridgeType = 0xff
 )
 
 /*
@@ -61,6 +73,14 @@ const (
  * Or 0x8000 < n bytes
  */
 func (m *GBMem) read(addr uint16) uint8 {
+	if m.dmaBlocks(addr) {
+		return 0xff
+	}
+	return m.readBus(addr)
+}
+
+// readBus reads addr without the CPU's DMA restrictions
+func (m *GBMem) readBus(addr uint16) uint8 {
 	if addr >= 0x0000 && addr < 0x8000 {
 		return m.cartridge.readROM(addr)
 	} else if addr >= 0x8000 && addr < 0xa000 {
@@ -83,23 +103,26 @@ func (m *GBMem) read(addr uint16) uint8 {
 		return m.wram[addr-0x2000-0xc000]
 	} else if addr >= 0xfe00 && addr < 0xfea0 {
 		/* OAM (Object Attribute Table) Sprite information table */
-		return uint8(0x00)
+		return m.oam[addr-0xfe00]
 	} else if addr >= 0xfea0 && addr < 0xff00 {
 		/* Unused */
 		return uint8(0x00)
 	} else if addr >= 0xff00 && addr < 0xff80 {
 		/* I/O Registers I/O registers are mapped here */
-		return m.ioregs[addr-0xff00]
+		return m.readIO(addr)
 	} else if addr >= 0xff80 && addr < 0xffff {
 		/* HRAM Internal CPU RAM */
 		return m.hram[addr-0xff80]
 	} else {
 		/* 0xffff - IE Register Interrupt enable flags */
-		return uint8(0x00)
+		return m.ie
 	}
 }
 
 func (m *GBMem) write(addr uint16, value uint8) {
+	if m.dmaBlocks(addr) {
+		return
+	}
 	if addr >= 0x0000 && addr < 0x8000 {
 		/*
 		 * Both non-switchable and switchable ROM Bank.
@@ -125,18 +148,26 @@ func (m *GBMem) write(addr uint16, value uint8) {
 		m.wram[addr-0x2000-0xc000] = value
 	} else if addr >= 0xfe00 && addr < 0xfea0 {
 		/* OAM (Object Attribute Table) Sprite information table */
+		m.oam[addr-0xfe00] = value
 	} else if addr >= 0xfea0 && addr < 0xff00 {
 		/* Unused */
 	} else if addr >= 0xff00 && addr < 0xff80 {
 		/* I/O Registers I/O registers are mapped here */
+		m.writeIO(addr, value)
 	} else if addr >= 0xff80 && addr < 0xffff {
 		/* HRAM Internal CPU RAM */
 		m.hram[addr-0xff80] = value
 	} else {
 		/* 0xffff - IE Register Interrupt enable flags */
+		m.ie = value
 	}
 }
 
+// requestInterrupt sets the given bit(s) in IF (0xff0f)
+func (m *GBMem) requestInterrupt(interrupt uint8) {
+	m.ioregs[0x0f] |= interrupt
+}
+
 func (m *GBMem) loadROM(data []uint8) {
 	m.cartridge.loadROM(data)
 }
@@ -157,6 +188,28 @@ type GBCartridge interface {
 	reader() *bytes.Reader
 }
 
+/* Implemented by cartridges with hardware of their own to clock, e.g. an RTC */
+type clockedCartridge interface {
+	tick(cycles int)
+}
+
+/* Implemented by cartridges that can have a rumble motor */
+type rumbleCartridge interface {
+	hasRumble() bool
+	onRumble(f func(on bool))
+}
+
+/*
+ * Implemented by cartridges with external RAM that a battery can keep alive.
+ * saveRAM returns the contents in the raw .sav layout other emulators use
+ * and resets ramDirty.
+ */
+type batteryCartridge interface {
+	saveRAM() []uint8
+	loadRAM(data []uint8) error
+	ramDirty() bool
+}
+
 func (m *GBMem) readN(address, n uint16) []uint8 {
 	var bytes []uint8
 	for i := uint16(0); i < n; i++ {
@@ -1565,9 +1565,10 @@ func (gb *GameBoy) RET(ins []uint8) int {
 	return 16
 }
 
-// RETI 1B
+// RETI 1B, unlike EI this sets IME immediately
 func (gb *GameBoy) RETI(ins []uint8) int {
 	gb.interruptEnabled = true
+	gb.imePending = false
 	address_lsb := gb.mainMemory.read(gb.get16Reg(SP))
 	gb.regs[SP]++
 	address_msb := gb.mainMemory.read(gb.get16Reg(SP))
@@ -1669,13 +1670,14 @@ func (gb *GameBoy) CCF(ins []uint8) int {
 // DI
 func (gb *GameBoy) DI(ins []uint8) int {
 	gb.interruptEnabled = false
+	gb.imePending = false
 	gb.regs[PC] += uint16(len(ins))
 	return 4
 }
 
-// EI
+// EI, IME is only set once the following instruction has executed
 func (gb *GameBoy) EI(ins []uint8) int {
-	gb.interruptEnabled = true
+	gb.imePending = true
 	gb.regs[PC] += uint16(len(ins))
 	return 4
 }
@@ -1685,3 +1687,29 @@ func (gb *GameBoy) NOP(ins []uint8) int {
 	gb.regs[PC] += uint16(len(ins))
 	return 4
 }
+
+// HALT 1B
+func (gb *GameBoy) HALT(ins []uint8) int {
+	gb.regs[PC] += uint16(len(ins))
+	pending := gb.mainMemory.read(RegIE)&gb.mainMemory.read(RegIF)&0x1f != 0
+	if pending && (!gb.interruptEnabled || gb.imePending) {
+		/*
+		 * HALT bug: with IME clear and an interrupt already pending the CPU
+		 * doesn't halt, and fails to increment PC after the next opcode
+		 * fetch so the byte after HALT is read twice. Straight after EI the
+		 * interrupt is dispatched instead, returning to the HALT itself.
+		 */
+		gb.haltBug = true
+	} else {
+		gb.halted = true
+	}
+	return 4
+}
+
+// STOP 2B, also resets DIV
+func (gb *GameBoy) STOP(ins []uint8) int {
+	gb.regs[PC] += uint16(len(ins))
+	gb.stopped = true
+	gb.mainMemory.write(RegDIV, 0)
+	return 4
+}
@@ -1407,7 +1407,40 @@ func TestDI(t *testing.T) {
 
 func TestEI(t *testing.T) {
 	gb := initGameboy()
+	gb.interruptEnabled = false
 	cycles := gb.EI([]uint8{0xfb})
 	assert.Equal(t, cycles, 4)
-	assert.True(t, gb.interruptEnabled)
+	assert.True(t, !gb.interruptEnabled)
+	assert.True(t, gb.imePending)
+}
+
+func TestHALT(t *testing.T) {
+	gb := initGameboy()
+	cycles := gb.HALT([]uint8{0x76})
+	assert.Equal(t, cycles, 4)
+	assert.Equal(t, gb.get16Reg(PC), uint16(0x0001))
+	assert.True(t, gb.halted)
+	assert.True(t, !gb.haltBug)
+}
+
+func TestHALTBug(t *testing.T) {
+	gb := initGameboy()
+	gb.interruptEnabled = false
+	gb.mainMemory.write(0xffff, 0x01)
+	gb.mainMemory.write(0xff0f, 0x01)
+	cycles := gb.HALT([]uint8{0x76})
+	assert.Equal(t, cycles, 4)
+	assert.True(t, !gb.halted)
+	assert.True(t, gb.haltBug)
+}
+
+func TestSTOP(t *testing.T) {
+	gb := initGameboy()
+	gb.timer = newTimer(gb.mainMemory)
+	gb.timer.divider = 0x4242
+	cycles := gb.STOP([]uint8{0x10, 0x00})
+	assert.Equal(t, cycles, 4)
+	assert.Equal(t, gb.get16Reg(PC), uint16(0x0002))
+	assert.True(t, gb.stopped)
+	assert.Equal(t, gb.mainMemory.read(0xff04), uint8(0x00))
 }
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+// ColorScheme maps the four DMG shades, lightest (0) to darkest (3), to RGBA
+type ColorScheme [4]color.RGBA
+
+const DefaultColorScheme = "grayscale"
+
+var colorSchemes = map[string]ColorScheme{
+	/* the pea soup green of the original DMG screen */
+	"dmg": {
+		{0x9b, 0xbc, 0x0f, 0xff},
+		{0x8b, 0xac, 0x0f, 0xff},
+		{0x30, 0x62, 0x30, 0xff},
+		{0x0f, 0x38, 0x0f, 0xff},
+	},
+	"grayscale": {
+		{0xff, 0xff, 0xff, 0xff},
+		{0xaa, 0xaa, 0xaa, 0xff},
+		{0x55, 0x55, 0x55, 0xff},
+		{0x00, 0x00, 0x00, 0xff},
+	},
+	/* GameBoy Pocket, a slightly tinted gray */
+	"pocket": {
+		{0xc4, 0xcf, 0xa1, 0xff},
+		{0x8b, 0x95, 0x6d, 0xff},
+		{0x4d, 0x53, 0x3c, 0xff},
+		{0x1f, 0x1f, 0x1f, 0xff},
+	},
+}
+
+/*
+ * BGP, OBP0 and OBP1 each hold four 2-bit shades, one per colour index:
+ * bits 0-1 are the shade of colour 0, bits 6-7 the shade of colour 3.
+ * Colour 0 of the object palettes is transparent and never looked up.
+ */
+func (m *GBMem) shade(palette uint16, colorIndex uint8) uint8 {
+	return selectSemiNibble(m.ioregs[palette-0xff00], colorIndex)
+}
+
+// loadColorScheme returns the named built in scheme, or reads a user
+// supplied one from a file of four colours, lightest first, as #rrggbb.
+func loadColorScheme(name string) (ColorScheme, error) {
+	if scheme, ok := colorSchemes[name]; ok {
+		return scheme, nil
+	}
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return ColorScheme{}, fmt.Errorf("unknown colour scheme %q: %v", name, err)
+	}
+	return parseColorScheme(string(data))
+}
+
+func parseColorScheme(data string) (ColorScheme, error) {
+	var scheme ColorScheme
+	fields := strings.Fields(data)
+	if len(fields) != len(scheme) {
+		return scheme, fmt.Errorf("colour scheme needs %d colours, got %d", len(scheme), len(fields))
+	}
+	for i, field := range fields {
+		hex := strings.TrimPrefix(strings.TrimPrefix(field, "#"), "0x")
+		rgb, err := strconv.ParseUint(hex, 16, 24)
+		if err != nil || len(hex) != 6 {
+			return scheme, fmt.Errorf("invalid colour %q", field)
+		}
+		scheme[i] = color.RGBA{uint8(rgb >> 16), uint8(rgb >> 8), uint8(rgb), 0xff}
+	}
+	return scheme, nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"image/color"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestShade(t *testing.T) {
+	mem := &GBMem{}
+	mem.write(RegBGP, 0xe4)
+	mem.write(RegOBP1, 0x1b)
+	for i := uint8(0); i < 4; i++ {
+		assert.Equal(t, i, mem.shade(RegBGP, i))
+		assert.Equal(t, 3-i, mem.shade(RegOBP1, i))
+	}
+}
+
+func TestRenderLinePalettes(t *testing.T) {
+	p, _ := initPPU()
+	p.mem.ioregs[RegLCDC-0xff00] = LCDCEnable | LCDCBGEnable | LCDCOBJEnable | LCDCTileData
+	p.mem.ioregs[RegBGP-0xff00] = 0x03  // colour 0 is black
+	p.mem.ioregs[RegOBP1-0xff00] = 0x08 // colour 1 is dark gray
+	writeTile(p.mem, VRAMTilePattern+16, 1)
+	writeSprite(p.mem, 0, 16, 8, 1, OAMPalette)
+	p.renderLine(0)
+	assert.Equal(t, p.scheme[2], p.image.RGBAAt(0, 0))
+	assert.Equal(t, p.scheme[3], p.image.RGBAAt(8, 0))
+}
+
+func TestLoadColorScheme(t *testing.T) {
+	scheme, err := loadColorScheme("pocket")
+	assert.Nil(t, err)
+	assert.Equal(t, colorSchemes["pocket"], scheme)
+
+	f, err := ioutil.TempFile("", "scheme")
+	assert.Nil(t, err)
+	defer os.Remove(f.Name())
+	f.WriteString("#e0f8d0 #88c070\n0x346856 081820\n")
+	f.Close()
+	scheme, err = loadColorScheme(f.Name())
+	assert.Nil(t, err)
+	assert.Equal(t, color.RGBA{0xe0, 0xf8, 0xd0, 0xff}, scheme[0])
+	assert.Equal(t, color.RGBA{0x08, 0x18, 0x20, 0xff}, scheme[3])
+
+	_, err = loadColorScheme("no-such-scheme")
+	assert.NotNil(t, err)
+	_, err = parseColorScheme("#ffffff #000000")
+	assert.NotNil(t, err)
+	_, err = parseColorScheme("#ffffff #000000 #zzzzzz #123456")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,228 @@
+package main
+
+import "image"
+
+/* LCD I/O registers */
+const (
+	RegLCDC = 0xff40 // LCD control
+	RegSTAT = 0xff41 // LCD status
+	RegSCY  = 0xff42 // background scroll Y
+	RegSCX  = 0xff43 // background scroll X
+	RegLY   = 0xff44 // current scanline
+	RegLYC  = 0xff45 // scanline compare
+	RegBGP  = 0xff47 // background palette
+	RegOBP0 = 0xff48 // object palette 0
+	RegOBP1 = 0xff49 // object palette 1
+	RegWY   = 0xff4a // window Y
+	RegWX   = 0xff4b // window X + 7
+)
+
+/* PPU modes as reported in STAT bits 0-1 */
+const (
+	ModeHBlank   uint8 = 0x00
+	ModeVBlank   uint8 = 0x01
+	ModeOAMScan  uint8 = 0x02
+	ModeTransfer uint8 = 0x03
+)
+
+/* Clocks spent in each mode of a visible scanline, 456 in total */
+const (
+	OAMScanCycles  = 80
+	TransferCycles = 172
+	HBlankCycles   = CyclesPerLine - OAMScanCycles - TransferCycles
+)
+
+const (
+	STATCoincidence = 0x04 // LY == LYC
+	STATHBlankInt   = 0x08 // mode 0 interrupt select
+	STATVBlankInt   = 0x10 // mode 1 interrupt select
+	STATOAMInt      = 0x20 // mode 2 interrupt select
+	STATLYCInt      = 0x40 // LY == LYC interrupt select
+)
+
+/*
+ * The pixel processing unit. Every scanline walks OAM scan (mode 2), pixel
+ * transfer (mode 3) and HBlank (mode 0); lines 144-153 are VBlank (mode 1).
+ * Each mode change is an event on the scheduler.
+ */
+type PPU struct {
+	mem       *GBMem
+	scheduler *Scheduler
+	image     *image.RGBA
+	scheme    ColorScheme
+	mode      uint8
+	enabled   bool
+	/*
+	 * The STAT interrupt is requested on the rising edge of the OR of all
+	 * enabled STAT conditions, so back to back conditions only fire once.
+	 */
+	statLine bool
+	frames   uint64 // number of frames completed
+	/* colour indices of the scanline being drawn, before palette lookup */
+	bgLine  [visibleWidth]uint8
+	objLine [visibleWidth]objPixel
+	/*
+	 * The window is only considered once LY has matched WY during the
+	 * frame, and fetches its rows from an internal counter rather than LY,
+	 * so a window hidden part way down the screen resumes where it left off.
+	 */
+	windowTriggered bool
+	windowLine      uint8
+}
+
+func newPPU(mem *GBMem, scheduler *Scheduler, image *image.RGBA) *PPU {
+	p := &PPU{
+		mem:       mem,
+		scheduler: scheduler,
+		image:     image,
+		scheme:    colorSchemes[DefaultColorScheme],
+	}
+	mem.hookIO(RegLCDC, nil, p.writeLCDC)
+	mem.hookIO(RegSTAT, nil, func(uint8) { p.updateSTATLine() })
+	mem.hookIO(RegLYC, nil, func(uint8) { p.compareLYC() })
+	return p
+}
+
+// reset puts the LCD in the state the boot ROM leaves it in and starts line 0
+func (p *PPU) reset(now uint64) {
+	p.mem.ioregs[RegLCDC-0xff00] = 0x91
+	p.mem.ioregs[RegBGP-0xff00] = 0xfc
+	p.scheduler.cancel(EventPPU)
+	p.startFrame(now)
+}
+
+func (p *PPU) startFrame(now uint64) {
+	p.enabled = true
+	p.resetWindow()
+	p.setLY(0)
+	p.setMode(ModeOAMScan)
+	p.scheduler.schedule(EventPPU, now+OAMScanCycles, p.step)
+}
+
+// writeLCDC switches the LCD on and off. While it is off LY is held at 0
+// in mode 0; switching it back on starts a new frame from line 0.
+func (p *PPU) writeLCDC(value uint8) {
+	on := value&LCDCEnable != 0
+	if on && !p.enabled {
+		p.startFrame(p.scheduler.now)
+	} else if !on && p.enabled {
+		p.scheduler.cancel(EventPPU)
+		p.enabled = false
+		p.setLY(0)
+		p.setMode(ModeHBlank)
+	}
+}
+
+// step runs at the end of each mode and moves on to the next one
+func (p *PPU) step(when uint64) {
+	var next uint64
+	LY := p.mem.ioregs[RegLY-0xff00]
+	switch p.mode {
+	case ModeOAMScan:
+		p.setMode(ModeTransfer)
+		next = TransferCycles
+	case ModeTransfer:
+		p.renderLine(LY)
+		p.setMode(ModeHBlank)
+		next = HBlankCycles
+	case ModeHBlank:
+		p.setLY(LY + 1)
+		if LY+1 == visibleHeight {
+			p.setMode(ModeVBlank)
+			p.mem.requestInterrupt(IntVBlank)
+			p.endFrame()
+			next = CyclesPerLine
+		} else {
+			p.setMode(ModeOAMScan)
+			next = OAMScanCycles
+		}
+	case ModeVBlank:
+		if LY+1 == LinesPerFrame {
+			p.resetWindow()
+			p.setLY(0)
+			p.setMode(ModeOAMScan)
+			next = OAMScanCycles
+		} else {
+			p.setLY(LY + 1)
+			next = CyclesPerLine
+		}
+	}
+	p.scheduler.schedule(EventPPU, when+next, p.step)
+}
+
+// endFrame is called on entry to VBlank once the visible area is complete
+func (p *PPU) endFrame() {
+	p.frames++
+}
+
+// renderLine draws visible scanline LY into the frame
+func (p *PPU) renderLine(LY uint8) {
+	drawBackgroundLine(&p.bgLine, p.mem, LY)
+	if LY == p.mem.ioregs[RegWY-0xff00] {
+		p.windowTriggered = true
+	}
+	if p.windowTriggered && drawWindowLine(&p.bgLine, p.mem, p.windowLine) {
+		p.windowLine++
+	}
+	drawSpriteLine(&p.objLine, p.mem, LY)
+	for x := 0; x < visibleWidth; x++ {
+		bg := p.bgLine[x]
+		obj := p.objLine[x]
+		shade := p.mem.shade(RegBGP, bg)
+		if obj.color != 0 && !(obj.behindBG && bg != 0) {
+			shade = p.mem.shade(obj.palette, obj.color)
+		}
+		p.image.SetRGBA(x, int(LY), p.scheme[shade])
+	}
+}
+
+func (p *PPU) resetWindow() {
+	p.windowTriggered = false
+	p.windowLine = 0
+}
+
+func (p *PPU) setMode(mode uint8) {
+	p.mode = mode
+	STAT := p.mem.ioregs[RegSTAT-0xff00]
+	p.mem.ioregs[RegSTAT-0xff00] = STAT&^0x03 | mode
+	p.updateSTATLine()
+}
+
+/*
+ * LYC and LY are continuously compared with each other. When both values are
+ * identical, the coincidence bit in the STAT register becomes set, and (if
+ * enabled) a STAT interrupt is requested.
+ */
+func (p *PPU) setLY(LY uint8) {
+	p.mem.ioregs[RegLY-0xff00] = LY
+	p.compareLYC()
+}
+
+func (p *PPU) compareLYC() {
+	STAT := p.mem.ioregs[RegSTAT-0xff00]
+	if p.mem.ioregs[RegLY-0xff00] == p.mem.ioregs[RegLYC-0xff00] {
+		STAT |= STATCoincidence
+	} else {
+		STAT &^= STATCoincidence
+	}
+	p.mem.ioregs[RegSTAT-0xff00] = STAT
+	p.updateSTATLine()
+}
+
+func (p *PPU) updateSTATLine() {
+	STAT := p.mem.ioregs[RegSTAT-0xff00]
+	line := STAT&STATLYCInt != 0 && STAT&STATCoincidence != 0
+	switch p.mode {
+	case ModeHBlank:
+		line = line || STAT&STATHBlankInt != 0
+	case ModeVBlank:
+		/* OAM select also fires at the start of VBlank on DMG */
+		line = line || STAT&(STATVBlankInt|STATOAMInt) != 0
+	case ModeOAMScan:
+		line = line || STAT&STATOAMInt != 0
+	}
+	if p.enabled && line && !p.statLine {
+		p.mem.requestInterrupt(IntLCDStat)
+	}
+	p.statLine = line
+}
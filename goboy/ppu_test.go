@@ -0,0 +1,110 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"image"
+	"testing"
+)
+
+func initPPU() (*PPU, *Scheduler) {
+	s := &Scheduler{}
+	p := newPPU(newGBMem(nil), s, image.NewRGBA(image.Rect(0, 0, visibleWidth, visibleHeight)))
+	p.reset(0)
+	return p, s
+}
+
+func TestPPUModeTiming(t *testing.T) {
+	p, s := initPPU()
+	assert.Equal(t, ModeOAMScan, p.mem.ioregs[RegSTAT-0xff00]&0x03)
+	s.run(OAMScanCycles)
+	assert.Equal(t, ModeTransfer, p.mem.ioregs[RegSTAT-0xff00]&0x03)
+	s.run(OAMScanCycles + TransferCycles)
+	assert.Equal(t, ModeHBlank, p.mem.ioregs[RegSTAT-0xff00]&0x03)
+	s.run(CyclesPerLine)
+	assert.Equal(t, ModeOAMScan, p.mem.ioregs[RegSTAT-0xff00]&0x03)
+	assert.Equal(t, uint8(1), p.mem.ioregs[RegLY-0xff00])
+}
+
+func TestPPUVBlank(t *testing.T) {
+	p, s := initPPU()
+	s.run(visibleHeight*CyclesPerLine - 1)
+	assert.Equal(t, uint8(0), p.mem.ioregs[0x0f]&IntVBlank)
+	s.run(visibleHeight * CyclesPerLine)
+	assert.Equal(t, ModeVBlank, p.mem.ioregs[RegSTAT-0xff00]&0x03)
+	assert.Equal(t, uint8(visibleHeight), p.mem.ioregs[RegLY-0xff00])
+	assert.Equal(t, IntVBlank, p.mem.ioregs[0x0f]&IntVBlank)
+	assert.Equal(t, uint64(1), p.frames)
+
+	/* wraps back to line 0 after line 153 */
+	s.run(CyclesPerFrame)
+	assert.Equal(t, uint8(0), p.mem.ioregs[RegLY-0xff00])
+	assert.Equal(t, ModeOAMScan, p.mem.ioregs[RegSTAT-0xff00]&0x03)
+}
+
+func TestPPULYCInterrupt(t *testing.T) {
+	p, s := initPPU()
+	p.mem.ioregs[RegLYC-0xff00] = 10
+	p.mem.ioregs[RegSTAT-0xff00] |= STATLYCInt
+	p.reset(0)
+	s.run(10*CyclesPerLine - 1)
+	assert.Equal(t, uint8(0), p.mem.ioregs[0x0f]&IntLCDStat)
+	assert.Equal(t, uint8(0), p.mem.ioregs[RegSTAT-0xff00]&STATCoincidence)
+	s.run(10 * CyclesPerLine)
+	assert.Equal(t, IntLCDStat, p.mem.ioregs[0x0f]&IntLCDStat)
+	assert.Equal(t, uint8(STATCoincidence), p.mem.ioregs[RegSTAT-0xff00]&STATCoincidence)
+}
+
+func TestPPULCDOff(t *testing.T) {
+	p, s := initPPU()
+	s.run(5 * CyclesPerLine)
+	p.mem.write(RegLCDC, 0x11)
+	assert.Equal(t, uint8(0), p.mem.read(RegLY))
+	assert.Equal(t, ModeHBlank, p.mem.read(RegSTAT)&0x03)
+	s.run(CyclesPerFrame)
+	assert.Equal(t, uint8(0), p.mem.read(RegLY))
+	assert.Equal(t, uint64(0), p.frames)
+
+	// switching back on restarts from line 0
+	p.mem.write(RegLCDC, 0x91)
+	assert.Equal(t, ModeOAMScan, p.mem.read(RegSTAT)&0x03)
+	s.run(CyclesPerFrame + 3*CyclesPerLine)
+	assert.Equal(t, uint8(3), p.mem.read(RegLY))
+}
+
+func TestPPURegisterWrites(t *testing.T) {
+	p, s := initPPU()
+	s.run(3 * CyclesPerLine)
+	// LY and the STAT mode / coincidence bits are read only
+	p.mem.write(RegLY, 0x42)
+	p.mem.write(RegSTAT, 0xff)
+	assert.Equal(t, uint8(3), p.mem.read(RegLY))
+	assert.Equal(t, 0x80|uint8(STATLYCInt|STATOAMInt|STATVBlankInt|STATHBlankInt)|ModeOAMScan,
+		p.mem.read(RegSTAT))
+
+	// writing LYC compares immediately
+	p.mem.write(RegLYC, 3)
+	assert.Equal(t, uint8(STATCoincidence), p.mem.read(RegSTAT)&STATCoincidence)
+	assert.Equal(t, IntLCDStat, p.mem.ioregs[0x0f]&IntLCDStat)
+}
+
+func TestPPUWindowLineCounter(t *testing.T) {
+	p, s := initPPU()
+	p.mem.ioregs[RegWY-0xff00] = 10
+	p.mem.ioregs[RegWX-0xff00] = 7
+	p.mem.ioregs[RegLCDC-0xff00] |= LCDCWindowEnable
+	s.run(20 * CyclesPerLine)
+	assert.Equal(t, uint8(10), p.windowLine)
+
+	// hiding the window freezes the counter
+	p.mem.ioregs[RegWX-0xff00] = 200
+	s.run(30 * CyclesPerLine)
+	assert.Equal(t, uint8(10), p.windowLine)
+	p.mem.ioregs[RegWX-0xff00] = 7
+	s.run(40 * CyclesPerLine)
+	assert.Equal(t, uint8(20), p.windowLine)
+
+	// and it is reset for the next frame
+	s.run(CyclesPerFrame + 5*CyclesPerLine)
+	assert.Equal(t, uint8(0), p.windowLine)
+	assert.False(t, p.windowTriggered)
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+/* How the MBC3 real time clock measures the passage of time */
+type RTCMode int
+
+const (
+	RTCWallClock RTCMode = iota // follows the host clock, like a real cartridge
+	RTCEmulated                 // counts emulated cycles, for reproducible runs
+)
+
+var rtcModes = map[string]RTCMode{
+	"wall":     RTCWallClock,
+	"emulated": RTCEmulated,
+}
+
+func parseRTCMode(name string) (RTCMode, error) {
+	if mode, ok := rtcModes[name]; ok {
+		return mode, nil
+	}
+	return 0, fmt.Errorf("unknown clock source %q, expected wall or emulated", name)
+}
+
+/* RTC registers, selected by writing 0x08 - 0x0c to 0x4000 - 0x5fff */
+const (
+	RTCSeconds = iota
+	RTCMinutes
+	RTCHours
+	RTCDayLow
+	RTCDayHigh // bit 0: day bit 8, bit 6: halt, bit 7: day counter carry
+)
+
+/*
+ * Size of the clock state appended to MBC3 save files: the registers and
+ * latched registers as 32-bit little endian words followed by a 64-bit
+ * UNIX timestamp, as written by BGB and VBA-M. Older files use a 32-bit
+ * timestamp and are 4 bytes shorter.
+ */
+const (
+	RTCSaveSize    = 48
+	RTCSaveSizeOld = 44
+)
+
+const (
+	RTCDayHighBit = 0x01
+	RTCHalt       = 0x40
+	RTCCarry      = 0x80
+)
+
+/*
+ * The MBC3 clock counts seconds, minutes, hours and a 9-bit day counter,
+ * setting a sticky carry bit when the days overflow. Games read it through
+ * a latched copy, updated by writing 0x00 then 0x01 to 0x6000 - 0x7fff.
+ */
+type RTC struct {
+	mode    RTCMode
+	regs    [5]uint8
+	latched [5]uint8
+	/* RTCEmulated: clocks counted towards the next second */
+	cycles uint64
+	/* RTCWallClock: host time the registers were last brought up to date */
+	last time.Time
+	now  func() time.Time
+}
+
+func newRTC(mode RTCMode) *RTC {
+	r := &RTC{mode: mode, now: time.Now}
+	r.last = r.now()
+	return r
+}
+
+// tick counts emulated clocks when the RTC isn't following wall time
+func (r *RTC) tick(cycles int) {
+	if r.mode != RTCEmulated {
+		return
+	}
+	r.cycles += uint64(cycles)
+	if r.cycles >= GBClockFrequency {
+		r.advance(r.cycles / GBClockFrequency)
+		r.cycles %= GBClockFrequency
+	}
+}
+
+// sync catches the registers up with the host clock
+func (r *RTC) sync() {
+	if r.mode != RTCWallClock {
+		return
+	}
+	elapsed := r.now().Sub(r.last)
+	if elapsed < time.Second {
+		return
+	}
+	seconds := elapsed / time.Second
+	r.last = r.last.Add(seconds * time.Second)
+	r.advance(uint64(seconds))
+}
+
+func (r *RTC) halted() bool {
+	return r.regs[RTCDayHigh]&RTCHalt != 0
+}
+
+func (r *RTC) advance(seconds uint64) {
+	if r.halted() {
+		return
+	}
+	s := uint64(r.regs[RTCSeconds]) + seconds
+	m := uint64(r.regs[RTCMinutes]) + s/60
+	h := uint64(r.regs[RTCHours]) + m/60
+	d := uint64(r.regs[RTCDayLow]) | uint64(r.regs[RTCDayHigh]&RTCDayHighBit)<<8
+	d += h / 24
+	r.regs[RTCSeconds] = uint8(s % 60)
+	r.regs[RTCMinutes] = uint8(m % 60)
+	r.regs[RTCHours] = uint8(h % 24)
+	r.regs[RTCDayLow] = uint8(d)
+	r.regs[RTCDayHigh] = r.regs[RTCDayHigh]&^RTCDayHighBit | uint8(d>>8)&RTCDayHighBit
+	if d >= 512 {
+		r.regs[RTCDayHigh] |= RTCCarry
+	}
+}
+
+func (r *RTC) latch() {
+	r.sync()
+	r.latched = r.regs
+}
+
+func (r *RTC) read(reg int) uint8 {
+	return r.latched[reg]
+}
+
+func (r *RTC) write(reg int, value uint8) {
+	r.sync()
+	switch reg {
+	case RTCSeconds:
+		/* writing the seconds restarts the current second */
+		r.cycles = 0
+		r.last = r.now()
+		value &= 0x3f
+	case RTCMinutes:
+		value &= 0x3f
+	case RTCHours:
+		value &= 0x1f
+	case RTCDayHigh:
+		value &= RTCDayHighBit | RTCHalt | RTCCarry
+		if r.halted() && value&RTCHalt == 0 {
+			/* time spent halted doesn't count */
+			r.last = r.now()
+		}
+	}
+	r.regs[reg] = value
+}
+
+// marshal returns the clock state in the RTCSaveSize save file layout
+func (r *RTC) marshal() []uint8 {
+	r.sync()
+	data := make([]uint8, RTCSaveSize)
+	for i := range r.regs {
+		binary.LittleEndian.PutUint32(data[4*i:], uint32(r.regs[i]))
+		binary.LittleEndian.PutUint32(data[20+4*i:], uint32(r.latched[i]))
+	}
+	stamp := r.last
+	if r.mode != RTCWallClock {
+		stamp = r.now()
+	}
+	binary.LittleEndian.PutUint64(data[40:], uint64(stamp.Unix()))
+	return data
+}
+
+// unmarshal restores the clock from a save file. Following the host clock,
+// it also catches up with the time spent switched off.
+func (r *RTC) unmarshal(data []uint8) error {
+	var stamp int64
+	switch len(data) {
+	case RTCSaveSize:
+		stamp = int64(binary.LittleEndian.Uint64(data[40:]))
+	case RTCSaveSizeOld:
+		stamp = int64(binary.LittleEndian.Uint32(data[40:]))
+	default:
+		return fmt.Errorf("RTC save data is %d bytes, expected %d", len(data), RTCSaveSize)
+	}
+	for i := range r.regs {
+		r.regs[i] = uint8(binary.LittleEndian.Uint32(data[4*i:]))
+		r.latched[i] = uint8(binary.LittleEndian.Uint32(data[20+4*i:]))
+	}
+	r.cycles = 0
+	r.last = r.now()
+	if r.mode == RTCWallClock {
+		/* a clock set backwards doesn't rewind the game */
+		if saved := time.Unix(stamp, 0); saved.Before(r.last) {
+			r.last = saved
+		}
+		r.sync()
+	}
+	return nil
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+/* Exit statuses of goboy run */
+const (
+	ExitOK           = 0 // a limit was reached, or the serial pass string seen
+	ExitFailed       = 1 // the serial fail string was seen
+	ExitInconclusive = 2 // stopped before either serial string was seen
+	ExitTimeout      = 3 // -timeout expired
+	ExitError        = 4 // bad arguments or the ROM couldn't be loaded
+	ExitInterrupted  = 5 // SIGINT or SIGTERM
+)
+
+/* Why runHeadless returned */
+type StopReason int
+
+const (
+	StopFrames StopReason = iota
+	StopCycles
+	StopPC
+	StopSerial
+	StopTimeout
+	StopInterrupted
+)
+
+var stopReasonNames = map[StopReason]string{
+	StopFrames:      "frame limit",
+	StopCycles:      "cycle limit",
+	StopPC:          "reached -until-pc",
+	StopSerial:      "serial capture finished",
+	StopTimeout:     "timeout",
+	StopInterrupted: "interrupted",
+}
+
+func (r StopReason) String() string {
+	return stopReasonNames[r]
+}
+
+/* Conditions ending a headless run, each ignored while zero (untilPC: negative) */
+type runLimits struct {
+	frames  uint64
+	cycles  uint64
+	untilPC int
+	timeout time.Duration
+}
+
+/* Steps between checks of the wall clock and for signals */
+const runPollSteps = 4096
+
+// runHeadless runs as fast as possible until one of the limits is reached,
+// the serial capture finishes, or something arrives on stop
+func runHeadless(g *GameBoy, limits runLimits, stop <-chan os.Signal) StopReason {
+	g.RealTime = false
+	g.Paused = false
+	var deadline time.Time
+	if limits.timeout > 0 {
+		deadline = time.Now().Add(limits.timeout)
+	}
+	for i := 0; ; i++ {
+		switch {
+		case limits.untilPC >= 0 && g.get16Reg(PC) == uint16(limits.untilPC):
+			return StopPC
+		case limits.frames > 0 && g.frames >= limits.frames:
+			return StopFrames
+		case limits.cycles > 0 && g.TSC >= limits.cycles:
+			return StopCycles
+		case g.finished():
+			return StopSerial
+		}
+		if i%runPollSteps == 0 {
+			if !deadline.IsZero() && time.Now().After(deadline) {
+				return StopTimeout
+			}
+			select {
+			case <-stop:
+				return StopInterrupted
+			default:
+			}
+		}
+		g.Step()
+	}
+}
+
+// exitStatus maps how a run ended onto the status goboy run exits with
+func exitStatus(g *GameBoy, reason StopReason) int {
+	switch reason {
+	case StopTimeout:
+		return ExitTimeout
+	case StopInterrupted:
+		return ExitInterrupted
+	}
+	if g.capture != nil {
+		return g.capture.exitStatus()
+	}
+	return ExitOK
+}
+
+func runUsage(fs *flag.FlagSet) func() {
+	return func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s run -rom FILE [options]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Runs without the debugger or real time pacing, then saves the final screen\n"+
+			"(see -screenshot) and prints the registers to stderr. Exit status:\n"+
+			"  %d  a limit was reached, or the serial pass string was seen\n"+
+			"  %d  the serial fail string was seen\n"+
+			"  %d  stopped before either serial string was seen\n"+
+			"  %d  timed out\n"+
+			"  %d  bad arguments or ROM\n"+
+			"  %d  interrupted\n\n"+
+			"Send SIGUSR1 to save a screenshot as the next goboy-NNNN.png.\n\n",
+			ExitOK, ExitFailed, ExitInconclusive, ExitTimeout, ExitError, ExitInterrupted)
+		fs.PrintDefaults()
+	}
+}
+
+// runMain implements goboy run, returning the exit status
+func runMain(args []string) int {
+	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+	fs.Usage = runUsage(fs)
+	o := options{headless: true}
+	o.register(fs)
+	frames := fs.Uint64("frames", 0, "stop after this many frames")
+	cycles := fs.Uint64("cycles", 0, "stop once this many clocks have run")
+	untilPC := fs.String("until-pc", "", "stop when PC reaches this address")
+	timeout := fs.Duration("timeout", 0, "stop after this much wall time, e.g. 30s")
+	screenshotPath := fs.String("screenshot", "",
+		"save the final screen as this PNG, none to skip (default: the ROM's name with .png)")
+	screenshotViews := fs.String("screenshot-views", "",
+		"also save these views next to the screenshot, comma separated: bg, tiles, oam")
+	if err := fs.Parse(args); err != nil {
+		return ExitError
+	}
+	limits := runLimits{frames: *frames, cycles: *cycles, untilPC: -1, timeout: *timeout}
+	if *untilPC != "" {
+		pc, err := strconv.ParseUint(*untilPC, 0, 16)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid address: %s\n", *untilPC)
+			return ExitError
+		}
+		limits.untilPC = int(pc)
+	}
+	var views []string
+	if *screenshotViews != "" {
+		views = strings.Split(*screenshotViews, ",")
+		for _, name := range views {
+			if _, err := parseScreenshotView(name); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return ExitError
+			}
+		}
+		if *screenshotPath == "none" {
+			fmt.Fprintln(os.Stderr, "-screenshot-views needs a screenshot")
+			return ExitError
+		}
+	}
+	if o.rom == "" {
+		fmt.Fprintln(os.Stderr, "goboy run needs a -rom")
+		return ExitError
+	}
+	if *screenshotPath == "" {
+		*screenshotPath = strings.TrimSuffix(o.rom, filepath.Ext(o.rom)) + ".png"
+	}
+
+	g, cleanup, err := setup(&o)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return ExitError
+	}
+	defer cleanup()
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(stop)
+	hotkey := make(chan os.Signal, 1)
+	signal.Notify(hotkey, syscall.SIGUSR1)
+	defer signal.Stop(hotkey)
+	go func() {
+		for range hotkey {
+			g.requestScreenshot()
+		}
+	}()
+
+	reason := runHeadless(g, limits, stop)
+	fmt.Fprintf(os.Stderr, "\nStopped: %s after %d frames, %d clocks\n", reason, g.frames, g.TSC)
+	g.dumpRegisters(os.Stderr)
+	if *screenshotPath != "none" {
+		if err := g.screenshot(*screenshotPath); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return ExitError
+		}
+		for _, name := range views {
+			view, _ := parseScreenshotView(name)
+			if err := g.saveView(view, viewPath(*screenshotPath, name)); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return ExitError
+			}
+		}
+	}
+	return exitStatus(g, reason)
+}
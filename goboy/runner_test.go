@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func noLimits() runLimits {
+	return runLimits{untilPC: -1}
+}
+
+func TestRunHeadlessLimits(t *testing.T) {
+	gb := initProgram(0x00, 0x00, 0x18, 0xfc) // nop; nop; jr -4
+	limits := noLimits()
+	limits.untilPC = 0xff82
+	assert.Equal(t, StopPC, runHeadless(gb, limits, nil))
+	assert.Equal(t, uint16(0xff82), gb.get16Reg(PC))
+	assert.False(t, gb.RealTime)
+
+	limits = noLimits()
+	limits.cycles = 10000
+	assert.Equal(t, StopCycles, runHeadless(gb, limits, nil))
+	assert.True(t, gb.TSC >= 10000 && gb.TSC < 10016)
+
+	limits = noLimits()
+	limits.frames = 3
+	assert.Equal(t, StopFrames, runHeadless(gb, limits, nil))
+	assert.Equal(t, uint64(3), gb.frames)
+
+	// frames pass with the LCD off too
+	gb = initProgram(0xaf, 0xe0, 0x40, 0x18, 0xfe) // xor a; ldh (LCDC), a; jr -2
+	limits.frames = 2
+	assert.Equal(t, StopFrames, runHeadless(gb, limits, nil))
+	assert.Equal(t, uint64(0), gb.ppu.frames)
+	assert.Equal(t, uint64(2), gb.frames)
+}
+
+func TestRunHeadlessStop(t *testing.T) {
+	gb := initProgram(0x18, 0xfe) // jr -2
+	limits := noLimits()
+	limits.timeout = time.Millisecond
+	assert.Equal(t, StopTimeout, runHeadless(gb, limits, nil))
+	assert.Equal(t, ExitTimeout, exitStatus(gb, StopTimeout))
+
+	stop := make(chan os.Signal, 1)
+	stop <- syscall.SIGINT
+	assert.Equal(t, StopInterrupted, runHeadless(gb, noLimits(), stop))
+	assert.Equal(t, ExitInterrupted, exitStatus(gb, StopInterrupted))
+}
+
+func TestRunHeadlessSerial(t *testing.T) {
+	gb := initProgram(
+		0x3e, 'F', // ld a, 'F'
+		0xe0, 0x01, // ldh (SB), a
+		0x3e, 0x81, // ld a, 0x81
+		0xe0, 0x02, // ldh (SC), a
+		0x18, 0xfe, // jr -2
+	)
+	var out bytes.Buffer
+	gb.captureSerial(newSerialCapture(&out, "P", "F"))
+	reason := runHeadless(gb, noLimits(), nil)
+	assert.Equal(t, StopSerial, reason)
+	assert.Equal(t, ExitFailed, exitStatus(gb, reason))
+
+	// hitting a limit while still waiting for a result is inconclusive
+	gb = initProgram(0x18, 0xfe)
+	gb.captureSerial(newSerialCapture(&out, "P", "F"))
+	limits := noLimits()
+	limits.cycles = 100
+	reason = runHeadless(gb, limits, nil)
+	assert.Equal(t, ExitInconclusive, exitStatus(gb, reason))
+	assert.Equal(t, ExitOK, exitStatus(initProgram(), StopCycles))
+}
+
+func TestRunMain(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goboy")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	rom := makeHeaderROM(0x8000, GBCartridgeROM, 0, 0)
+	copy(rom[0x100:], []uint8{0x00, 0x18, 0xfe}) // nop; jr -2
+	romPath := filepath.Join(dir, "loop.gb")
+	assert.Nil(t, ioutil.WriteFile(romPath, rom, 0644))
+	png := filepath.Join(dir, "final.png")
+
+	assert.Equal(t, ExitOK, runMain([]string{"-rom", romPath, "-frames", "2", "-screenshot", png}))
+	_, err = os.Stat(png)
+	assert.Nil(t, err)
+	// without -screenshot the final screen goes next to the ROM
+	assert.Equal(t, ExitOK, runMain([]string{"-rom", romPath, "-until-pc", "0x101"}))
+	_, err = os.Stat(filepath.Join(dir, "loop.png"))
+	assert.Nil(t, err)
+	assert.Nil(t, os.Remove(filepath.Join(dir, "loop.png")))
+	assert.Equal(t, ExitOK, runMain([]string{"-rom", romPath, "-frames", "1", "-screenshot", "none"}))
+	_, err = os.Stat(filepath.Join(dir, "loop.png"))
+	assert.True(t, os.IsNotExist(err))
+	assert.Equal(t, ExitError, runMain([]string{"-rom", romPath, "-screenshot", "none", "-screenshot-views", "bg"}))
+
+	// only the serial output goes to stdout
+	copy(rom[0x100:], []uint8{
+		0x3e, 'A', 0xe0, 0x01, // ld a, 'A'; ldh (SB), a
+		0x3e, 0x81, 0xe0, 0x02, // ld a, 0x81; ldh (SC), a
+		0x18, 0xfe, // jr -2
+	})
+	assert.Nil(t, ioutil.WriteFile(romPath, rom, 0644))
+	stdout, err := os.Create(filepath.Join(dir, "stdout"))
+	assert.Nil(t, err)
+	saved := os.Stdout
+	os.Stdout = stdout
+	status := runMain([]string{"-rom", romPath, "-frames", "1", "-serial-out", "-"})
+	os.Stdout = saved
+	stdout.Close()
+	assert.Equal(t, ExitOK, status)
+	out, err := ioutil.ReadFile(stdout.Name())
+	assert.Nil(t, err)
+	assert.Equal(t, "A", string(out))
+
+	assert.Equal(t, ExitError, runMain([]string{}))
+	assert.Equal(t, ExitError, runMain([]string{"-rom", romPath, "-until-pc", "nowhere"}))
+	assert.Equal(t, ExitError, runMain([]string{"-rom", filepath.Join(dir, "missing.gb")}))
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+/* How often battery RAM is written back while running, in emulated clocks */
+const AutosaveInterval = 5 * GBClockFrequency
+
+/*
+ * Persists battery backed cartridge RAM in a .sav file. The file holds the
+ * raw RAM contents (plus the clock state for MBC3 timer cartridges), the
+ * same layout most other emulators use, so saves can be moved between them.
+ */
+type SaveFile struct {
+	path      string
+	cartridge batteryCartridge
+}
+
+// saveFilePath names the save file after the ROM, in saveDir if it isn't empty
+func saveFilePath(romPath, saveDir string) string {
+	name := strings.TrimSuffix(romPath, filepath.Ext(romPath)) + ".sav"
+	if saveDir != "" {
+		name = filepath.Join(saveDir, filepath.Base(name))
+	}
+	return name
+}
+
+// openSaveFile loads an existing save into the cartridge. A missing file is
+// not an error; it is created on the first flush.
+func openSaveFile(path string, cartridge batteryCartridge) (*SaveFile, error) {
+	s := &SaveFile{path: path, cartridge: cartridge}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := cartridge.loadRAM(data); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// flush writes the RAM out if it changed since the last flush. It goes via
+// a temporary file so an interrupted write can't destroy the old save.
+func (s *SaveFile) flush() error {
+	if !s.cartridge.ramDirty() {
+		return nil
+	}
+	tmp := s.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, s.cartridge.saveRAM(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSaveFilePath(t *testing.T) {
+	assert.Equal(t, "roms/tetris.sav", saveFilePath("roms/tetris.gb", ""))
+	assert.Equal(t, "roms/pokemon.red.sav", saveFilePath("roms/pokemon.red.gb", ""))
+	assert.Equal(t, "saves/tetris.sav", saveFilePath("roms/tetris.gb", "saves"))
+}
+
+func TestSaveFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goboy")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "game.sav")
+
+	r := newGBMBC1(0x2000)
+	s, err := openSaveFile(path, r)
+	assert.Nil(t, err)
+	// nothing is written until the RAM changes
+	assert.Nil(t, s.flush())
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+
+	r.writeROM(0x0000, 0x0a)
+	r.writeRAM(0xa000, 0x12)
+	r.writeRAM(0xbfff, 0x34)
+	assert.True(t, r.ramDirty())
+	assert.Nil(t, s.flush())
+	assert.False(t, r.ramDirty())
+	data, err := ioutil.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Equal(t, 0x2000, len(data))
+	assert.Equal(t, uint8(0x12), data[0])
+	assert.Equal(t, uint8(0x34), data[0x1fff])
+
+	// writing the same value back doesn't need a save
+	r.writeRAM(0xa000, 0x12)
+	assert.False(t, r.ramDirty())
+
+	r = newGBMBC1(0x2000)
+	_, err = openSaveFile(path, r)
+	assert.Nil(t, err)
+	r.writeROM(0x0000, 0x0a)
+	assert.Equal(t, uint8(0x34), r.readRAM(0xbfff))
+}
+
+func TestAutosave(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goboy")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "game.sav")
+
+	r := newGBMBC5(0x2000, false)
+	s, err := openSaveFile(path, r)
+	assert.Nil(t, err)
+	g := NewGameBoy(r)
+	g.RealTime = false
+	g.attachSaveFile(s)
+	r.writeROM(0x0000, 0x0a)
+	r.writeRAM(0xa000, 0x99)
+	for g.TSC < AutosaveInterval-4 {
+		g.tick(4)
+	}
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+	g.tick(4)
+	data, err := ioutil.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Equal(t, uint8(0x99), data[0])
+}
@@ -0,0 +1,74 @@
+package main
+
+import "sort"
+
+/*
+ * The scheduler is a queue of events keyed on the emulated clock (TSC).
+ * Instead of every piece of hardware running off its own ticker goroutine,
+ * subsystems ask to be called back at an absolute cycle count and the CPU
+ * advances the clock by the cycles each instruction takes. Everything is
+ * therefore deterministic and in lock step with the opcode handlers.
+ */
+
+// EventKind identifies who owns a scheduled event so it can be cancelled
+type EventKind int
+
+const (
+	EventPPU EventKind = iota
+	EventFrame
+	EventAutosave
+	EventSerial
+)
+
+type event struct {
+	kind EventKind
+	when uint64
+	/* handler receives the cycle the event was due, not the current TSC */
+	handler func(when uint64)
+}
+
+type Scheduler struct {
+	now    uint64  /* TSC as of the last run */
+	events []event /* sorted by when, ties in insertion order */
+}
+
+// schedule registers handler to run once TSC reaches when
+func (s *Scheduler) schedule(kind EventKind, when uint64, handler func(uint64)) {
+	i := sort.Search(len(s.events), func(i int) bool {
+		return s.events[i].when > when
+	})
+	s.events = append(s.events, event{})
+	copy(s.events[i+1:], s.events[i:])
+	s.events[i] = event{kind: kind, when: when, handler: handler}
+}
+
+// cancel removes every pending event of the given kind
+func (s *Scheduler) cancel(kind EventKind) {
+	events := s.events[:0]
+	for _, e := range s.events {
+		if e.kind != kind {
+			events = append(events, e)
+		}
+	}
+	s.events = events
+}
+
+// next returns the cycle of the earliest pending event
+func (s *Scheduler) next() (uint64, bool) {
+	if len(s.events) == 0 {
+		return 0, false
+	}
+	return s.events[0].when, true
+}
+
+// run fires every event that is due at or before now, in order. Handlers
+// may schedule new events, which also fire if they are already due.
+func (s *Scheduler) run(now uint64) {
+	for len(s.events) > 0 && s.events[0].when <= now {
+		e := s.events[0]
+		s.events = s.events[1:]
+		s.now = e.when
+		e.handler(e.when)
+	}
+	s.now = now
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestSchedulerOrder(t *testing.T) {
+	s := &Scheduler{}
+	var fired []uint64
+	handler := func(when uint64) { fired = append(fired, when) }
+	s.schedule(EventPPU, 30, handler)
+	s.schedule(EventPPU, 10, handler)
+	s.schedule(EventFrame, 20, handler)
+	s.run(25)
+	assert.Equal(t, []uint64{10, 20}, fired)
+	s.run(30)
+	assert.Equal(t, []uint64{10, 20, 30}, fired)
+}
+
+func TestSchedulerCancel(t *testing.T) {
+	s := &Scheduler{}
+	fired := 0
+	s.schedule(EventPPU, 10, func(uint64) { fired++ })
+	s.schedule(EventFrame, 10, func(uint64) { fired += 10 })
+	s.cancel(EventPPU)
+	s.run(10)
+	assert.Equal(t, 10, fired)
+}
+
+func TestSchedulerReschedule(t *testing.T) {
+	s := &Scheduler{}
+	count := 0
+	var handler func(uint64)
+	handler = func(when uint64) {
+		count++
+		s.schedule(EventPPU, when+CyclesPerLine, handler)
+	}
+	s.schedule(EventPPU, CyclesPerLine, handler)
+	s.run(CyclesPerFrame)
+	assert.Equal(t, LinesPerFrame, count)
+}
+
+func TestStepAdvancesTSC(t *testing.T) {
+	gb := NewGameBoy(newGBROM())
+	gb.RealTime = false
+	/* ROM is all zeroes, i.e. NOPs */
+	for i := 0; i < CyclesPerLine/4; i++ {
+		assert.Equal(t, 4, gb.Step())
+	}
+	assert.Equal(t, uint64(CyclesPerLine), gb.TSC)
+	assert.Equal(t, uint8(1), gb.mainMemory.ioregs[0x44])
+}
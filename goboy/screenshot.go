@@ -0,0 +1,190 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+/* What a screenshot shows */
+type ScreenshotView int
+
+const (
+	ViewScreen     ScreenshotView = iota // the visible 160x144 screen
+	ViewBackground                       // the whole 256x256 background map
+	ViewTiles                            // the 384 tiles in VRAM, 16 to a row
+	ViewOAM                              // the 40 sprites in OAM order, 8 to a row
+)
+
+var screenshotViews = map[string]ScreenshotView{
+	"screen": ViewScreen,
+	"bg":     ViewBackground,
+	"tiles":  ViewTiles,
+	"oam":    ViewOAM,
+}
+
+func parseScreenshotView(name string) (ScreenshotView, error) {
+	if view, ok := screenshotViews[name]; ok {
+		return view, nil
+	}
+	return 0, fmt.Errorf("unknown view %q, expected screen, bg, tiles or oam", name)
+}
+
+const (
+	TileSheetColumns = 16
+	TileSheetTiles   = (VRAMTilePatternEnd + 1 - VRAMTilePattern) / 16 /* 384 */
+	OAMViewColumns   = 8
+)
+
+// drawTileSheet renders every tile in VRAM with the colour scheme applied
+// to the raw colour indices, as no one palette fits all of them
+func drawTileSheet(mem *GBMem, scheme ColorScheme) *image.RGBA {
+	rows := TileSheetTiles / TileSheetColumns
+	sheet := image.NewRGBA(image.Rect(0, 0, TileSheetColumns*TileWidth, rows*TileHeight))
+	for i := 0; i < TileSheetTiles; i++ {
+		tileAddr := uint16(VRAMTilePattern + i*16)
+		x0, y0 := i%TileSheetColumns*TileWidth, i/TileSheetColumns*TileHeight
+		for y := 0; y < TileHeight; y++ {
+			for x := 0; x < TileWidth; x++ {
+				sheet.SetRGBA(x0+x, y0+y, scheme[tilePixel(mem, tileAddr, uint8(x), uint8(y))])
+			}
+		}
+	}
+	return sheet
+}
+
+// drawOAM renders each sprite through its palette and flips, leaving
+// colour 0 transparent
+func drawOAM(mem *GBMem, scheme ColorScheme) *image.RGBA {
+	height := spriteHeight(mem.ioregs[RegLCDC-0xff00])
+	sprites := OAMSize / 4
+	rows := sprites / OAMViewColumns
+	view := image.NewRGBA(image.Rect(0, 0, OAMViewColumns*TileWidth, rows*height))
+	for i := 0; i < sprites; i++ {
+		tile, attrs := mem.oam[i*4+2], mem.oam[i*4+3]
+		if height == 16 {
+			tile &^= 0x01
+		}
+		palette := uint16(RegOBP0)
+		if attrs&OAMPalette != 0 {
+			palette = RegOBP1
+		}
+		x0, y0 := i%OAMViewColumns*TileWidth, i/OAMViewColumns*height
+		for row := 0; row < height; row++ {
+			ty := row
+			if attrs&OAMYFlip != 0 {
+				ty = height - 1 - row
+			}
+			tileAddr := VRAMTilePattern + uint16(tile)*16 + uint16(ty/TileHeight)*16
+			for col := 0; col < TileWidth; col++ {
+				tx := uint8(col)
+				if attrs&OAMXFlip != 0 {
+					tx = TileWidth - 1 - tx
+				}
+				color := tilePixel(mem, tileAddr, tx, uint8(ty%TileHeight))
+				if color != 0 {
+					view.SetRGBA(x0+col, y0+row, scheme[mem.shade(palette, color)])
+				}
+			}
+		}
+	}
+	return view
+}
+
+// renderView draws the current state of VRAM for the given view
+func (g *GameBoy) renderView(view ScreenshotView) image.Image {
+	switch view {
+	case ViewBackground:
+		bg := image.NewRGBA(image.Rect(0, 0, screenWidth, screenHeight))
+		return drawBackground(bg, g.mainMemory, g.ppu.scheme)
+	case ViewTiles:
+		return drawTileSheet(g.mainMemory, g.ppu.scheme)
+	case ViewOAM:
+		return drawOAM(g.mainMemory, g.ppu.scheme)
+	}
+	return g.image
+}
+
+// savePNG writes img to path as a PNG file
+func savePNG(path string, img image.Image) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (g *GameBoy) saveView(view ScreenshotView, path string) error {
+	return savePNG(path, g.renderView(view))
+}
+
+// screenshot saves the visible 160x144 screen
+func (g *GameBoy) screenshot(path string) error {
+	return g.saveView(ViewScreen, path)
+}
+
+// viewPath names the file for another view next to a screenshot,
+// e.g. shot.png becomes shot-tiles.png
+func viewPath(path string, name string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "-" + name + ext
+}
+
+/* Screenshots are numbered goboy-0001.png up to this */
+const MaxScreenshots = 9999
+
+// nextScreenshotPath returns the first goboy-NNNN.png in dir not yet taken
+func nextScreenshotPath(dir string) (string, error) {
+	for i := 1; i <= MaxScreenshots; i++ {
+		path := filepath.Join(dir, fmt.Sprintf("goboy-%04d.png", i))
+		_, err := os.Stat(path)
+		if os.IsNotExist(err) {
+			return path, nil
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	return "", fmt.Errorf("%s already has %d screenshots", dir, MaxScreenshots)
+}
+
+// screenshotHotkey saves the screen under the next free name in dir and
+// returns the file written
+func (g *GameBoy) screenshotHotkey(dir string) (string, error) {
+	path, err := nextScreenshotPath(dir)
+	if err != nil {
+		return "", err
+	}
+	return path, g.screenshot(path)
+}
+
+// requestScreenshot asks for a screenshot in the current directory once
+// the frame being drawn is complete. It is safe to call from another
+// goroutine, e.g. a signal handler.
+func (g *GameBoy) requestScreenshot() {
+	select {
+	case g.screenshots <- struct{}{}:
+	default: /* one is already on the way */
+	}
+}
+
+// takeScreenshots saves the screenshot asked for during the last frame
+func (g *GameBoy) takeScreenshots() {
+	select {
+	case <-g.screenshots:
+		path, err := g.screenshotHotkey(".")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "screenshot: %s\n", err)
+			return
+		}
+		fmt.Fprintf(os.Stderr, "Saved %s\n", path)
+	default:
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"image"
+	"image/color"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestScreenshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goboy")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "screen.png")
+
+	gb := initProgram()
+	gb.image.Set(3, 4, colorSchemes["dmg"][3])
+	assert.Nil(t, gb.screenshot(path))
+	f, err := os.Open(path)
+	assert.Nil(t, err)
+	defer f.Close()
+	img, err := png.Decode(f)
+	assert.Nil(t, err)
+	assert.Equal(t, visibleWidth, img.Bounds().Dx())
+	assert.Equal(t, visibleHeight, img.Bounds().Dy())
+	r, g, b, _ := img.At(3, 4).RGBA()
+	want := colorSchemes["dmg"][3]
+	assert.Equal(t, [3]uint32{uint32(want.R) * 0x101, uint32(want.G) * 0x101, uint32(want.B) * 0x101}, [3]uint32{r, g, b})
+
+	assert.NotNil(t, gb.screenshot(filepath.Join(dir, "missing", "screen.png")))
+}
+
+func TestScreenshotViews(t *testing.T) {
+	gb := initProgram()
+	scheme := gb.ppu.scheme
+	mem := gb.mainMemory
+	writeTile(mem, VRAMTilePattern+16*1, 1)
+	writeTile(mem, VRAMTilePattern+16*383, 3)
+
+	tiles := gb.renderView(ViewTiles)
+	assert.Equal(t, image.Rect(0, 0, 128, 192), tiles.Bounds())
+	assert.Equal(t, scheme[0], tiles.At(0, 0))
+	assert.Equal(t, scheme[1], tiles.At(8, 0))
+	assert.Equal(t, scheme[3], tiles.At(127, 191))
+
+	bg := gb.renderView(ViewBackground)
+	assert.Equal(t, image.Rect(0, 0, screenWidth, screenHeight), bg.Bounds())
+
+	// sprite 9 uses tile 1 through OBP1, flipped so its top row is clear
+	mem.write(RegOBP1, 0x0c) // colour 1 is shade 3
+	mem.oam[9*4+2] = 1
+	mem.oam[9*4+3] = OAMPalette
+	mem.write(RegLCDC, mem.read(RegLCDC)|LCDCOBJSize)
+	oam := gb.renderView(ViewOAM)
+	assert.Equal(t, image.Rect(0, 0, 64, 80), oam.Bounds())
+	assert.Equal(t, color.RGBA{}, oam.At(0, 0))
+	// 8x16 sprites ignore bit 0 of the tile, so tile 0 (all colour 0) is on top
+	assert.Equal(t, color.RGBA{}, oam.At(8, 16))
+	assert.Equal(t, scheme[3], oam.At(8, 24))
+	mem.oam[9*4+3] |= OAMYFlip
+	oam = gb.renderView(ViewOAM)
+	assert.Equal(t, scheme[3], oam.At(8, 16))
+	assert.Equal(t, color.RGBA{}, oam.At(8, 24))
+
+	assert.Equal(t, gb.image, gb.renderView(ViewScreen))
+}
+
+func TestScreenshotNames(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goboy")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	gb := initProgram()
+	path, err := gb.screenshotHotkey(dir)
+	assert.Nil(t, err)
+	assert.Equal(t, filepath.Join(dir, "goboy-0001.png"), path)
+	path, err = gb.screenshotHotkey(dir)
+	assert.Nil(t, err)
+	assert.Equal(t, filepath.Join(dir, "goboy-0002.png"), path)
+
+	// a directory that can't be checked is an error, not an endless search
+	notDir := filepath.Join(dir, "goboy-0001.png")
+	_, err = nextScreenshotPath(notDir)
+	assert.NotNil(t, err)
+	_, err = gb.screenshotHotkey(notDir)
+	assert.NotNil(t, err)
+
+	assert.Equal(t, "out/shot-tiles.png", viewPath("out/shot.png", "tiles"))
+	view, err := parseScreenshotView("oam")
+	assert.Nil(t, err)
+	assert.Equal(t, ViewOAM, view)
+	_, err = parseScreenshotView("window")
+	assert.NotNil(t, err)
+}
+
+func TestScreenshotRequest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goboy")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	wd, err := os.Getwd()
+	assert.Nil(t, err)
+	assert.Nil(t, os.Chdir(dir))
+	defer os.Chdir(wd)
+
+	gb := initProgram(0x18, 0xfe) // jr -2
+	gb.requestScreenshot()
+	gb.requestScreenshot()
+	_, err = os.Stat("goboy-0001.png")
+	assert.True(t, os.IsNotExist(err))
+	// saved once, when the frame ends
+	for gb.TSC < CyclesPerFrame {
+		gb.Step()
+	}
+	_, err = os.Stat("goboy-0001.png")
+	assert.Nil(t, err)
+	_, err = os.Stat("goboy-0002.png")
+	assert.True(t, os.IsNotExist(err))
+}
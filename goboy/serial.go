@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+/* SC bits */
+const (
+	SCTransfer      = 0x80 // set to start, cleared when the byte is done
+	SCInternalClock = 0x01
+)
+
+/* 8192Hz with the internal clock */
+const SerialBitCycles = GBClockFrequency / 8192
+
+/*
+ * The serial port shifts SB out one bit at a time, most significant bit
+ * first, shifting the other side's bits in at the bottom. With the
+ * internal clock the GameBoy drives a bit every SerialBitCycles; with the
+ * external clock the transfer waits for the other side to drive it. After
+ * 8 bits SCTransfer is cleared and the serial interrupt requested.
+ *
+ * With nothing connected, the internal clock shifts in 1s and an external
+ * clock transfer never finishes.
+ */
+type Serial struct {
+	mem       *GBMem
+	scheduler *Scheduler
+	link      *Link /* nil without a cable */
+	bits      int   /* bits shifted so far with the internal clock */
+	incoming  uint8 /* byte being shifted in */
+	replied   bool  /* incoming holds the other side's byte */
+	/* OnTransmit is called with each byte sent using the internal clock */
+	OnTransmit func(data uint8)
+}
+
+func newSerial(mem *GBMem, scheduler *Scheduler) *Serial {
+	s := &Serial{mem: mem, scheduler: scheduler}
+	mem.hookIO(RegSC, nil, s.writeSC)
+	return s
+}
+
+func (s *Serial) connect(link *Link) {
+	s.link = link
+}
+
+func (s *Serial) writeSC(value uint8) {
+	s.scheduler.cancel(EventSerial)
+	if value&SCTransfer == 0 {
+		return
+	}
+	now := s.scheduler.now
+	if value&SCInternalClock == 0 {
+		if s.link != nil {
+			s.scheduler.schedule(EventSerial, now+SerialBitCycles, s.pollLink)
+		}
+		return
+	}
+	if s.OnTransmit != nil {
+		s.OnTransmit(s.mem.ioregs[RegSB-0xff00])
+	}
+	s.bits = 0
+	s.incoming = 0xff
+	s.replied = false
+	if s.link != nil {
+		s.link.transfer(s.mem.ioregs[RegSB-0xff00])
+	}
+	s.scheduler.schedule(EventSerial, now+SerialBitCycles, s.shiftBit)
+}
+
+// shiftBit clocks one bit with the internal clock
+func (s *Serial) shiftBit(when uint64) {
+	if s.link != nil && !s.replied {
+		/* the first bit needs the other side's byte, so wait for it */
+		data, ok, err := s.link.reply()
+		if err != nil {
+			s.disconnect(err)
+		}
+		if !ok {
+			s.scheduler.schedule(EventSerial, when+SerialBitCycles, s.shiftBit)
+			return
+		}
+		s.incoming = data
+		s.replied = true
+	}
+	SB := &s.mem.ioregs[RegSB-0xff00]
+	*SB = *SB<<1 | s.incoming>>uint(7-s.bits)&1
+	s.bits++
+	if s.bits < 8 {
+		s.scheduler.schedule(EventSerial, when+SerialBitCycles, s.shiftBit)
+		return
+	}
+	s.finish()
+}
+
+// pollLink waits for the other side to clock a transfer in
+func (s *Serial) pollLink(when uint64) {
+	data, ok, err := s.link.poll()
+	if err != nil {
+		/* like a transfer with nothing connected, this one never finishes */
+		s.disconnect(err)
+		return
+	}
+	if !ok {
+		s.scheduler.schedule(EventSerial, when+SerialBitCycles, s.pollLink)
+		return
+	}
+	SB := &s.mem.ioregs[RegSB-0xff00]
+	s.link.answer(*SB)
+	*SB = data
+	s.finish()
+}
+
+// disconnect carries on as if the cable had been pulled out
+func (s *Serial) disconnect(err error) {
+	fmt.Fprintf(os.Stderr, "link: %s\n", err)
+	s.link = nil
+}
+
+func (s *Serial) finish() {
+	s.mem.ioregs[RegSC-0xff00] &^= SCTransfer
+	s.mem.requestInterrupt(IntSerial)
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func initSerial() *Serial {
+	return newSerial(&GBMem{}, &Scheduler{})
+}
+
+// runSerial advances the serial port's scheduler by cycles
+func runSerial(s *Serial, cycles int) {
+	s.scheduler.run(s.scheduler.now + uint64(cycles))
+}
+
+func TestSerialInternalClock(t *testing.T) {
+	s := initSerial()
+	s.mem.write(RegSB, 0x5a)
+	s.mem.write(RegSC, SCTransfer|SCInternalClock)
+	assert.Equal(t, uint8(0xff), s.mem.read(RegSC))
+	// one bit every 512 clocks, shifting in 1s with nothing connected
+	runSerial(s, SerialBitCycles)
+	assert.Equal(t, uint8(0xb5), s.mem.read(RegSB))
+	runSerial(s, SerialBitCycles*6)
+	assert.Equal(t, uint8(0x7f), s.mem.read(RegSB))
+	assert.Equal(t, uint8(0), s.mem.ioregs[RegIF-0xff00]&IntSerial)
+	runSerial(s, SerialBitCycles)
+	assert.Equal(t, uint8(0xff), s.mem.read(RegSB))
+	assert.Equal(t, uint8(0x7f), s.mem.read(RegSC))
+	assert.Equal(t, IntSerial, s.mem.ioregs[RegIF-0xff00]&IntSerial)
+}
+
+func TestSerialExternalClock(t *testing.T) {
+	s := initSerial()
+	s.mem.write(RegSB, 0x5a)
+	s.mem.write(RegSC, SCTransfer)
+	// nobody drives the clock
+	runSerial(s, SerialBitCycles*100)
+	assert.Equal(t, uint8(0x5a), s.mem.read(RegSB))
+	assert.Equal(t, uint8(0xfe), s.mem.read(RegSC))
+	assert.Equal(t, uint8(0), s.mem.ioregs[RegIF-0xff00]&IntSerial)
+}
+
+func TestSerialCancel(t *testing.T) {
+	s := initSerial()
+	s.mem.write(RegSC, SCTransfer|SCInternalClock)
+	runSerial(s, SerialBitCycles*4)
+	s.mem.write(RegSC, SCInternalClock)
+	runSerial(s, SerialBitCycles*8)
+	assert.Equal(t, uint8(0), s.mem.ioregs[RegIF-0xff00]&IntSerial)
+}
@@ -0,0 +1,111 @@
+package main
+
+import "sort"
+
+const (
+	OAMStart       = 0xfe00 // 0xfe00-0xfe9f
+	OAMSize        = 0xa0   // 40 sprites of 4 bytes
+	SpritesPerLine = 10     // the PPU only picks the first 10 matches in OAM
+)
+
+/* OAM attribute byte (byte 3) */
+const (
+	OAMPriority = 0x80 // BG colours 1-3 are drawn over the sprite
+	OAMYFlip    = 0x40
+	OAMXFlip    = 0x20
+	OAMPalette  = 0x10 // 0 = OBP0, 1 = OBP1
+)
+
+type sprite struct {
+	y     int // screen Y, i.e. OAM byte 0 - 16
+	x     int // screen X, i.e. OAM byte 1 - 8
+	tile  uint8
+	attrs uint8
+	index int // position in OAM, breaks ties on X
+}
+
+// objPixel is a sprite pixel waiting to be composited with the background
+type objPixel struct {
+	color    uint8 // 0 is transparent
+	palette  uint16
+	behindBG bool
+}
+
+func spriteHeight(lcdc uint8) int {
+	if lcdc&LCDCOBJSize != 0 {
+		return 16
+	}
+	return 8
+}
+
+// scanOAM returns the sprites on scanline LY as mode 2 would select them:
+// the first 10 in OAM order whose rows cover LY, regardless of X.
+func scanOAM(mem *GBMem, LY uint8) []sprite {
+	height := spriteHeight(mem.ioregs[RegLCDC-0xff00])
+	sprites := make([]sprite, 0, SpritesPerLine)
+	for i := 0; i < OAMSize && len(sprites) < SpritesPerLine; i += 4 {
+		y := int(mem.oam[i]) - 16
+		if int(LY) < y || int(LY) >= y+height {
+			continue
+		}
+		sprites = append(sprites, sprite{
+			y:     y,
+			x:     int(mem.oam[i+1]) - 8,
+			tile:  mem.oam[i+2],
+			attrs: mem.oam[i+3],
+			index: i / 4,
+		})
+	}
+	return sprites
+}
+
+// drawSpriteLine fills line with the sprite pixels of scanline LY. On DMG the
+// sprite with the smaller X wins where sprites overlap, then the one earlier
+// in OAM. A winning sprite hides the others even if it is behind the BG.
+func drawSpriteLine(line *[visibleWidth]objPixel, mem *GBMem, LY uint8) {
+	*line = [visibleWidth]objPixel{}
+	lcdc := mem.ioregs[RegLCDC-0xff00]
+	if lcdc&LCDCOBJEnable == 0 {
+		return
+	}
+	height := spriteHeight(lcdc)
+	sprites := scanOAM(mem, LY)
+	sort.SliceStable(sprites, func(i, j int) bool {
+		return sprites[i].x < sprites[j].x
+	})
+	for _, s := range sprites {
+		row := int(LY) - s.y
+		if s.attrs&OAMYFlip != 0 {
+			row = height - 1 - row
+		}
+		tile := s.tile
+		if height == 16 {
+			/* bit 0 of the tile index is ignored for 8x16 sprites */
+			tile &^= 0x01
+		}
+		tileAddr := VRAMTilePattern + uint16(tile)*16 + uint16(row/TileHeight)*16
+		palette := uint16(RegOBP0)
+		if s.attrs&OAMPalette != 0 {
+			palette = RegOBP1
+		}
+		for col := 0; col < TileWidth; col++ {
+			x := s.x + col
+			if x < 0 || x >= visibleWidth || line[x].color != 0 {
+				continue
+			}
+			px := uint8(col)
+			if s.attrs&OAMXFlip != 0 {
+				px = TileWidth - 1 - px
+			}
+			color := tilePixel(mem, tileAddr, px, uint8(row%TileHeight))
+			if color == 0 {
+				continue
+			}
+			line[x] = objPixel{
+				color:    color,
+				palette:  palette,
+				behindBG: s.attrs&OAMPriority != 0,
+			}
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// writeSprite fills OAM entry i
+func writeSprite(mem *GBMem, i int, y, x, tile, attrs uint8) {
+	mem.write(uint16(OAMStart+i*4), y)
+	mem.write(uint16(OAMStart+i*4+1), x)
+	mem.write(uint16(OAMStart+i*4+2), tile)
+	mem.write(uint16(OAMStart+i*4+3), attrs)
+}
+
+func initSpriteMem() *GBMem {
+	mem := &GBMem{}
+	mem.ioregs[RegLCDC-0xff00] = LCDCEnable | LCDCBGEnable | LCDCOBJEnable
+	return mem
+}
+
+func TestOAMReadWrite(t *testing.T) {
+	mem := &GBMem{}
+	mem.write(0xfe00, 0x12)
+	mem.write(0xfe9f, 0x34)
+	assert.Equal(t, uint8(0x12), mem.read(0xfe00))
+	assert.Equal(t, uint8(0x34), mem.read(0xfe9f))
+}
+
+func TestScanOAMLimit(t *testing.T) {
+	mem := initSpriteMem()
+	for i := 0; i < 12; i++ {
+		// X = 0 hides the sprite but it still counts towards the limit
+		writeSprite(mem, i, 16, 0, 0, 0)
+	}
+	sprites := scanOAM(mem, 0)
+	assert.Equal(t, SpritesPerLine, len(sprites))
+	assert.Equal(t, 9, sprites[9].index)
+	assert.Equal(t, 0, len(scanOAM(mem, 8)))
+}
+
+func TestDrawSpriteLineFlip(t *testing.T) {
+	mem := initSpriteMem()
+	// tile 1: left column colour 1, row 0 colour 3
+	mem.vram[16] = 0xff
+	mem.vram[17] = 0xff
+	for row := 1; row < 8; row++ {
+		mem.vram[16+2*row] = 0x80
+	}
+	writeSprite(mem, 0, 16, 8, 1, 0)
+	var line [visibleWidth]objPixel
+	drawSpriteLine(&line, mem, 0)
+	assert.Equal(t, uint8(3), line[0].color)
+	assert.Equal(t, uint16(RegOBP0), line[0].palette)
+	drawSpriteLine(&line, mem, 1)
+	assert.Equal(t, uint8(1), line[0].color)
+	assert.Equal(t, uint8(0), line[7].color)
+
+	writeSprite(mem, 0, 16, 8, 1, OAMXFlip|OAMYFlip|OAMPalette)
+	drawSpriteLine(&line, mem, 7)
+	assert.Equal(t, uint8(3), line[0].color)
+	assert.Equal(t, uint16(RegOBP1), line[0].palette)
+	drawSpriteLine(&line, mem, 1)
+	assert.Equal(t, uint8(0), line[0].color)
+	assert.Equal(t, uint8(1), line[7].color)
+
+	// sprites disabled
+	mem.ioregs[RegLCDC-0xff00] &^= LCDCOBJEnable
+	drawSpriteLine(&line, mem, 1)
+	assert.Equal(t, uint8(0), line[7].color)
+}
+
+func TestDrawSpriteLineTall(t *testing.T) {
+	mem := initSpriteMem()
+	mem.ioregs[RegLCDC-0xff00] |= LCDCOBJSize
+	writeTile(mem, VRAMTilePattern+2*16, 1)
+	writeTile(mem, VRAMTilePattern+3*16, 2)
+	// bit 0 of the tile index is ignored
+	writeSprite(mem, 0, 16, 8, 3, 0)
+	var line [visibleWidth]objPixel
+	drawSpriteLine(&line, mem, 0)
+	assert.Equal(t, uint8(1), line[0].color)
+	drawSpriteLine(&line, mem, 15)
+	assert.Equal(t, uint8(2), line[0].color)
+
+	writeSprite(mem, 0, 16, 8, 2, OAMYFlip)
+	drawSpriteLine(&line, mem, 0)
+	assert.Equal(t, uint8(2), line[0].color)
+}
+
+func TestDrawSpriteLinePriority(t *testing.T) {
+	mem := initSpriteMem()
+	writeTile(mem, VRAMTilePattern+16, 1)
+	writeTile(mem, VRAMTilePattern+32, 2)
+	// later in OAM but further left: wins the overlap
+	writeSprite(mem, 0, 16, 12, 1, 0)
+	writeSprite(mem, 1, 16, 10, 2, 0)
+	// same X as sprite 1 but later in OAM: loses
+	writeSprite(mem, 2, 16, 10, 1, 0)
+	var line [visibleWidth]objPixel
+	drawSpriteLine(&line, mem, 0)
+	assert.Equal(t, uint8(2), line[2].color)
+	assert.Equal(t, uint8(2), line[9].color)
+	assert.Equal(t, uint8(1), line[10].color)
+}
+
+func TestRenderLineBGPriority(t *testing.T) {
+	p, _ := initPPU()
+	p.mem.ioregs[RegLCDC-0xff00] = LCDCEnable | LCDCBGEnable | LCDCOBJEnable | LCDCTileData
+	p.mem.ioregs[RegBGP-0xff00] = 0xe4
+	p.mem.ioregs[RegOBP0-0xff00] = 0xe4
+	// BG: left tile colour 0, next tile colour 2
+	writeTile(p.mem, VRAMTilePattern+16, 2)
+	p.mem.vram[VRAMBackgroundMap-0x8000+1] = 1
+	writeTile(p.mem, VRAMTilePattern+32, 1)
+	writeSprite(p.mem, 0, 16, 12, 2, OAMPriority)
+	p.renderLine(0)
+	// over BG colour 0 the sprite shows, over colour 2 it is hidden
+	assert.Equal(t, p.scheme[1], p.image.RGBAAt(4, 0))
+	assert.Equal(t, p.scheme[2], p.image.RGBAAt(8, 0))
+}
@@ -0,0 +1,90 @@
+package main
+
+const TACEnable = 0x04
+
+/*
+ * Divider bit that clocks TIMA for each TAC input clock select:
+ * 00 - 4096Hz, 01 - 262144Hz, 10 - 65536Hz, 11 - 16384Hz
+ */
+var timerBits = [4]uint16{1 << 9, 1 << 3, 1 << 5, 1 << 7}
+
+/*
+ * The timer is a 16-bit divider counting every clock, of which DIV is the
+ * upper byte. TIMA counts on the falling edge of (TAC enable AND the
+ * selected divider bit), which is why writing DIV or TAC can bump TIMA.
+ * When TIMA overflows it reads 0 for one machine cycle before being
+ * reloaded from TMA and requesting the timer interrupt.
+ */
+type Timer struct {
+	mem     *GBMem
+	divider uint16
+	signal  bool /* last value of the AND gate feeding TIMA */
+	reload  bool /* TIMA overflowed last machine cycle */
+	cycles  int  /* clocks carried over that don't make up a machine cycle yet */
+}
+
+func newTimer(mem *GBMem) *Timer {
+	t := &Timer{mem: mem}
+	mem.hookIO(RegDIV, t.readDIV, t.writeDIV)
+	mem.hookIO(RegTIMA, nil, t.writeTIMA)
+	mem.hookIO(RegTAC, nil, func(uint8) { t.updateSignal() })
+	return t
+}
+
+// reset puts the divider where the boot ROM leaves it
+func (t *Timer) reset() {
+	t.divider = 0xabcc
+	t.reload = false
+	t.cycles = 0
+	t.updateSignal()
+}
+
+// step advances the timer one machine cycle at a time
+func (t *Timer) step(cycles int) {
+	t.cycles += cycles
+	for t.cycles >= 4 {
+		t.cycles -= 4
+		if t.reload {
+			t.reload = false
+			t.mem.ioregs[RegTIMA-0xff00] = t.mem.ioregs[RegTMA-0xff00]
+			t.mem.requestInterrupt(IntTimer)
+		}
+		t.divider += 4
+		t.updateSignal()
+	}
+}
+
+func (t *Timer) updateSignal() {
+	TAC := t.mem.ioregs[RegTAC-0xff00]
+	signal := TAC&TACEnable != 0 && t.divider&timerBits[TAC&0x03] != 0
+	if t.signal && !signal {
+		t.incrementTIMA()
+	}
+	t.signal = signal
+}
+
+func (t *Timer) incrementTIMA() {
+	t.mem.ioregs[RegTIMA-0xff00]++
+	if t.mem.ioregs[RegTIMA-0xff00] == 0 {
+		t.reload = true
+	}
+}
+
+func (t *Timer) readDIV() uint8 {
+	return uint8(t.divider >> 8)
+}
+
+// writeDIV resets the whole divider whatever the value written
+func (t *Timer) writeDIV(uint8) {
+	t.resetDivider()
+}
+
+func (t *Timer) resetDivider() {
+	t.divider = 0
+	t.updateSignal()
+}
+
+// writeTIMA during the cycle after an overflow cancels the TMA reload
+func (t *Timer) writeTIMA(uint8) {
+	t.reload = false
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func initTimer() *Timer {
+	t := newTimer(&GBMem{})
+	t.divider = 0
+	return t
+}
+
+func TestTimerDIV(t *testing.T) {
+	timer := initTimer()
+	timer.step(255)
+	assert.Equal(t, uint8(0), timer.mem.read(RegDIV))
+	timer.step(1)
+	assert.Equal(t, uint8(1), timer.mem.read(RegDIV))
+	timer.step(256 * 255)
+	assert.Equal(t, uint8(0), timer.mem.read(RegDIV))
+	timer.step(0x1000)
+	timer.mem.write(RegDIV, 0x42)
+	assert.Equal(t, uint8(0), timer.mem.read(RegDIV))
+	assert.Equal(t, uint16(0), timer.divider)
+}
+
+func TestTimerFrequencies(t *testing.T) {
+	for tac, period := range []int{1024, 16, 64, 256} {
+		timer := initTimer()
+		timer.mem.write(RegTAC, TACEnable|uint8(tac))
+		timer.step(period - 4)
+		assert.Equal(t, uint8(0), timer.mem.read(RegTIMA))
+		timer.step(4)
+		assert.Equal(t, uint8(1), timer.mem.read(RegTIMA))
+		timer.step(period * 9)
+		assert.Equal(t, uint8(10), timer.mem.read(RegTIMA))
+	}
+}
+
+func TestTimerDisabled(t *testing.T) {
+	timer := initTimer()
+	timer.mem.write(RegTAC, 0x01)
+	timer.step(1024)
+	assert.Equal(t, uint8(0), timer.mem.read(RegTIMA))
+}
+
+func TestTimerOverflowReload(t *testing.T) {
+	timer := initTimer()
+	timer.mem.write(RegTMA, 0x80)
+	timer.mem.write(RegTIMA, 0xff)
+	timer.mem.write(RegTAC, TACEnable|0x01)
+	timer.step(16)
+	// TIMA reads 0 for one machine cycle before the reload
+	assert.Equal(t, uint8(0x00), timer.mem.read(RegTIMA))
+	assert.Equal(t, uint8(0), timer.mem.ioregs[RegIF-0xff00]&IntTimer)
+	timer.step(4)
+	assert.Equal(t, uint8(0x80), timer.mem.read(RegTIMA))
+	assert.Equal(t, IntTimer, timer.mem.ioregs[RegIF-0xff00]&IntTimer)
+}
+
+func TestTimerOverflowCancelled(t *testing.T) {
+	timer := initTimer()
+	timer.mem.write(RegTMA, 0x80)
+	timer.mem.write(RegTIMA, 0xff)
+	timer.mem.write(RegTAC, TACEnable|0x01)
+	timer.step(16)
+	timer.mem.write(RegTIMA, 0x10)
+	timer.step(4)
+	assert.Equal(t, uint8(0x10), timer.mem.read(RegTIMA))
+	assert.Equal(t, uint8(0), timer.mem.ioregs[RegIF-0xff00]&IntTimer)
+}
+
+func TestTimerDIVWriteGlitch(t *testing.T) {
+	timer := initTimer()
+	timer.mem.write(RegTAC, TACEnable|0x01)
+	timer.step(8)
+	// bit 3 is set, resetting the divider is a falling edge
+	timer.mem.write(RegDIV, 0)
+	assert.Equal(t, uint8(1), timer.mem.read(RegTIMA))
+	timer.step(4)
+	timer.mem.write(RegDIV, 0)
+	assert.Equal(t, uint8(1), timer.mem.read(RegTIMA))
+}
+
+func TestTimerTACWriteGlitch(t *testing.T) {
+	timer := initTimer()
+	timer.mem.write(RegTAC, TACEnable|0x01)
+	timer.step(8)
+	// disabling the timer while the selected bit is high counts once
+	timer.mem.write(RegTAC, 0x01)
+	assert.Equal(t, uint8(1), timer.mem.read(RegTIMA))
+}
@@ -2,14 +2,28 @@ package main
 
 import (
 	"image"
-	"image/color"
 )
 
 const (
 	VRAMTilePattern      = 0x8000 // 0x8000-0x97FF
 	VRAMTilePatternEnd   = 0x97FF // 0x8000-0x97FF
+	VRAMTilePatternBase  = 0x9000 // tile 0 in signed (0x8800) addressing mode
 	VRAMBackgroundMap    = 0x9800 // 0x9800-0x9BFF
 	VRAMBackgroundMapEnd = 0x9BFF // 0x9800-0x9BFF
+	VRAMAlternateMap     = 0x9C00 // 0x9C00-0x9FFF
+	VRAMAlternateMapEnd  = 0x9FFF // 0x9C00-0x9FFF
+)
+
+/* LCDC (0xff40) bits */
+const (
+	LCDCBGEnable     = 0x01 // BG and window display, blank (colour 0) when clear
+	LCDCOBJEnable    = 0x02 // sprite display
+	LCDCOBJSize      = 0x04 // 0 = 8x8, 1 = 8x16
+	LCDCBGMap        = 0x08 // BG tile map, 0 = 0x9800, 1 = 0x9C00
+	LCDCTileData     = 0x10 // BG & window tile data, 0 = 0x8800 signed, 1 = 0x8000 unsigned
+	LCDCWindowEnable = 0x20 // window display
+	LCDCWindowMap    = 0x40 // window tile map, 0 = 0x9800, 1 = 0x9C00
+	LCDCEnable       = 0x80 // LCD and PPU on
 )
 
 const (
@@ -19,14 +33,6 @@ const (
 	MapHeight  = 32 // The map is 32 tiles tall
 )
 
-// paletteMap maps color index to RGBA color. TODO: use register values
-var paletteMap = map[uint8]color.RGBA{
-	0x00: color.RGBA{0x00, 0x00, 0x00, 0xff},
-	0x01: color.RGBA{0x33, 0x33, 0x33, 0xff},
-	0x02: color.RGBA{0xcc, 0xcc, 0xcc, 0xff},
-	0x03: color.RGBA{0xff, 0xff, 0xff, 0xff},
-}
-
 // selectSemiNibble picks the semi-nibble from input given index
 func selectSemiNibble(input uint8, index uint8) uint8 {
 	return uint8(input>>uint8(index*2)) & 0x03
@@ -36,48 +42,90 @@ func compositePixel(lsb, hsb, index uint8) uint8 {
 	return (lsb>>index)&0x1 | (hsb>>index)&0x1<<1
 }
 
-// tileToPixel returns an 8x8 array of RGBA pixel values. It inputs a tile index.
-// This is then converted to the raw pointer to the tile data which is a set of
-// 8 16-bit unsigned integers. From here it iterates through each 2-bit pixel
-// value and translates that into a 32-bit 8x8 array
-func tileToPixel(tileIndex uint8, mem *GBMem) [TileHeight][TileWidth]color.RGBA {
-	var pixels [TileHeight][TileWidth]color.RGBA
-	for y := 0; y < TileHeight; y++ {
-		var line [TileWidth]color.RGBA
-		lsb := mem.vram[int(tileIndex)*16+2*y]
-		hsb := mem.vram[int(tileIndex)*16+2*y+1]
-
-		for x := 0; x < TileWidth; x++ {
-			line[TileWidth-1-x] = paletteMap[compositePixel(lsb, hsb, uint8(x))]
+// drawBackground draws the whole 256x256 background tile map onto an image
+func drawBackground(image *image.RGBA, mem *GBMem, scheme ColorScheme) *image.RGBA {
+	lcdc := mem.ioregs[RegLCDC-0xff00]
+	mapBase := tileMapAddress(lcdc, LCDCBGMap)
+	for x := 0; x < MapWidth; x++ {
+		for y := 0; y < MapHeight; y++ {
+			tileIndex := mem.vram[int(mapBase)-0x8000+x+y*MapWidth]
+			tileAddr := tileDataAddress(lcdc, tileIndex)
+			for ty := 0; ty < TileHeight; ty++ {
+				for tx := 0; tx < TileWidth; tx++ {
+					shade := mem.shade(RegBGP, tilePixel(mem, tileAddr, uint8(tx), uint8(ty)))
+					image.SetRGBA(x*TileWidth+tx, y*TileHeight+ty, scheme[shade])
+				}
+			}
 		}
-		pixels[y] = line
 	}
-	return pixels
+	return image
 }
 
-// drawTilePixels draws an 8x8 tile onto an image
-func drawTilePixels(image *image.RGBA, pixel [8][8]color.RGBA, xOffset int, yOffset int) *image.RGBA {
-	for x := 0; x < TileWidth; x++ {
-		for y := 0; y < TileHeight; y++ {
-			image.SetRGBA(xOffset*TileWidth+x, yOffset*TileHeight+y, pixel[y][x])
-		}
+// tileMapAddress returns 0x9800 or 0x9C00 depending on the given LCDC select bit
+func tileMapAddress(lcdc uint8, selectBit uint8) uint16 {
+	if lcdc&selectBit != 0 {
+		return VRAMAlternateMap
 	}
-	return image
+	return VRAMBackgroundMap
 }
 
-// drawBackground draws the background tile map onto an image
-func drawBackground(image *image.RGBA, mem *GBMem) *image.RGBA {
-	for x := 0; x < MapWidth; x++ {
-		for y := 0; y < MapHeight; y++ {
-			// get tile index
-			tileIndex := mem.read(uint16(VRAMBackgroundMap + x + (y * MapHeight)))
+// tileDataAddress returns the address of a BG/window tile. In 0x8000 mode
+// the index is unsigned, in 0x8800 mode it is signed relative to 0x9000.
+func tileDataAddress(lcdc uint8, tileIndex uint8) uint16 {
+	if lcdc&LCDCTileData != 0 {
+		return VRAMTilePattern + uint16(tileIndex)*16
+	}
+	return uint16(int(VRAMTilePatternBase) + int(int8(tileIndex))*16)
+}
 
-			// get pixels corresponding to tile index
-			pixels := tileToPixel(tileIndex, mem)
+// tilePixel returns the 2-bit colour index of pixel (x, y) of the tile at tileAddr
+func tilePixel(mem *GBMem, tileAddr uint16, x, y uint8) uint8 {
+	lsb := mem.vram[int(tileAddr)-0x8000+2*int(y)]
+	hsb := mem.vram[int(tileAddr)-0x8000+2*int(y)+1]
+	return compositePixel(lsb, hsb, TileWidth-1-x)
+}
 
-			// draw pixels
-			drawTilePixels(image, pixels, x, y)
-		}
+// drawBackgroundLine fills line with the BG colour indices of visible scanline
+// LY, scrolled by SCX/SCY and wrapping around the 256x256 map.
+func drawBackgroundLine(line *[visibleWidth]uint8, mem *GBMem, LY uint8) {
+	lcdc := mem.ioregs[RegLCDC-0xff00]
+	if lcdc&LCDCBGEnable == 0 {
+		*line = [visibleWidth]uint8{}
+		return
 	}
-	return image
+	mapBase := int(tileMapAddress(lcdc, LCDCBGMap)) - 0x8000
+	y := LY + mem.ioregs[RegSCY-0xff00]
+	scx := mem.ioregs[RegSCX-0xff00]
+	for x := 0; x < visibleWidth; x++ {
+		px := uint8(x) + scx
+		tileIndex := mem.vram[mapBase+int(y/TileHeight)*MapWidth+int(px/TileWidth)]
+		line[x] = tilePixel(mem, tileDataAddress(lcdc, tileIndex), px%TileWidth, y%TileHeight)
+	}
+}
+
+// drawWindowLine overlays the window onto line for visible scanline LY.
+// windowLine is the PPU's internal window line counter, which only advances
+// on scanlines where the window was actually drawn. Returns whether any
+// window pixels were drawn.
+func drawWindowLine(line *[visibleWidth]uint8, mem *GBMem, windowLine uint8) bool {
+	lcdc := mem.ioregs[RegLCDC-0xff00]
+	if lcdc&LCDCWindowEnable == 0 || lcdc&LCDCBGEnable == 0 {
+		return false
+	}
+	/* WX is the window's screen X + 7, anything past 166 is off screen */
+	wx := int(mem.ioregs[RegWX-0xff00]) - 7
+	if wx >= visibleWidth {
+		return false
+	}
+	mapBase := int(tileMapAddress(lcdc, LCDCWindowMap)) - 0x8000
+	start := wx
+	if start < 0 {
+		start = 0
+	}
+	for x := start; x < visibleWidth; x++ {
+		px := uint8(x - wx)
+		tileIndex := mem.vram[mapBase+int(windowLine/TileHeight)*MapWidth+int(px/TileWidth)]
+		line[x] = tilePixel(mem, tileDataAddress(lcdc, tileIndex), px%TileWidth, windowLine%TileHeight)
+	}
+	return true
 }
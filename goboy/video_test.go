@@ -1,33 +1,15 @@
 package main
 
 import (
+	"github.com/stretchr/testify/assert"
 	"image"
-	"image/png"
-	"log"
-	"os"
 	"testing"
 )
 
-// dumpPng will save PNG file to filename of image
-func dumpPng(filename string, image *image.RGBA) {
-	// Save image for testing
-	f, err := os.Create(filename)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	if err := png.Encode(f, image); err != nil {
-		f.Close()
-		log.Fatal(err)
-	}
-
-	if err := f.Close(); err != nil {
-		log.Fatal(err)
-	}
-}
-
 func TestVideo(t *testing.T) {
 	mem := &GBMem{}
+	mem.write(RegLCDC, 0x91)
+	mem.write(RegBGP, 0xe4) // identity palette
 
 	// write grid tile data
 	addr := uint16(VRAMTilePattern)
@@ -51,9 +33,104 @@ func TestVideo(t *testing.T) {
 	bgImage := image.NewRGBA(image.Rect(0, 0, 256, 256))
 
 	// drawImage
-	bgImage = drawBackground(bgImage, mem)
+	scheme := colorSchemes[DefaultColorScheme]
+	bgImage = drawBackground(bgImage, mem, scheme)
+
+	// top and bottom rows are colour 3, the left edge 1, right edge 2
+	assert.Equal(t, scheme[3], bgImage.RGBAAt(0, 0))
+	assert.Equal(t, scheme[3], bgImage.RGBAAt(12, 15))
+	assert.Equal(t, scheme[1], bgImage.RGBAAt(8, 1))
+	assert.Equal(t, scheme[2], bgImage.RGBAAt(15, 1))
+	assert.Equal(t, scheme[0], bgImage.RGBAAt(3, 3))
 
 	// save image for manual inspection
-	// FIXME: add actual assertion
-	dumpPng("image.png", bgImage)
+	assert.Nil(t, savePNG("image.png", bgImage))
+}
+
+// writeTile fills tile data at addr with a single colour index
+func writeTile(mem *GBMem, addr uint16, colorIndex uint8) {
+	var lsb, hsb uint8
+	if colorIndex&0x01 != 0 {
+		lsb = 0xff
+	}
+	if colorIndex&0x02 != 0 {
+		hsb = 0xff
+	}
+	for i := uint16(0); i < 16; i += 2 {
+		mem.vram[addr-0x8000+i] = lsb
+		mem.vram[addr-0x8000+i+1] = hsb
+	}
+}
+
+func TestDrawBackgroundLineScroll(t *testing.T) {
+	mem := &GBMem{}
+	mem.ioregs[RegLCDC-0xff00] = LCDCEnable | LCDCBGEnable | LCDCTileData
+	writeTile(mem, VRAMTilePattern+16, 1)
+	writeTile(mem, VRAMTilePattern+32, 2)
+	// tile (2, 3) of the map is tile 1, tile (0, 0) is tile 2
+	mem.vram[VRAMBackgroundMap-0x8000+3*MapWidth+2] = 1
+	mem.vram[VRAMBackgroundMap-0x8000] = 2
+
+	var line [visibleWidth]uint8
+	mem.ioregs[RegSCY-0xff00] = 20
+	mem.ioregs[RegSCX-0xff00] = 12
+	drawBackgroundLine(&line, mem, 4)
+	assert.Equal(t, uint8(1), line[4])
+	assert.Equal(t, uint8(1), line[11])
+	assert.Equal(t, uint8(0), line[12])
+
+	// scrolling wraps around the 256x256 map
+	mem.ioregs[RegSCY-0xff00] = 250
+	mem.ioregs[RegSCX-0xff00] = 252
+	drawBackgroundLine(&line, mem, 6)
+	assert.Equal(t, uint8(0), line[3])
+	assert.Equal(t, uint8(2), line[4])
+	assert.Equal(t, uint8(2), line[11])
+}
+
+func TestDrawBackgroundLineSignedTiles(t *testing.T) {
+	mem := &GBMem{}
+	mem.ioregs[RegLCDC-0xff00] = LCDCEnable | LCDCBGEnable | LCDCBGMap
+	writeTile(mem, VRAMTilePatternBase, 1)
+	writeTile(mem, VRAMTilePatternBase-16, 2)
+	mem.vram[VRAMAlternateMap-0x8000] = 0x00
+	mem.vram[VRAMAlternateMap-0x8000+1] = 0xff // tile -1 at 0x8ff0
+	// the 0x9800 map must be ignored
+	mem.vram[VRAMBackgroundMap-0x8000] = 0x01
+
+	var line [visibleWidth]uint8
+	drawBackgroundLine(&line, mem, 0)
+	assert.Equal(t, uint8(1), line[0])
+	assert.Equal(t, uint8(2), line[8])
+
+	// BG disabled draws colour 0
+	mem.ioregs[RegLCDC-0xff00] &^= LCDCBGEnable
+	drawBackgroundLine(&line, mem, 0)
+	assert.Equal(t, uint8(0), line[0])
+	assert.Equal(t, uint8(0), line[8])
+}
+
+func TestDrawWindowLine(t *testing.T) {
+	mem := &GBMem{}
+	mem.ioregs[RegLCDC-0xff00] = LCDCEnable | LCDCBGEnable | LCDCTileData | LCDCWindowEnable | LCDCWindowMap
+	writeTile(mem, VRAMTilePattern+16, 3)
+	// window row 1, column 0 is tile 1
+	mem.vram[VRAMAlternateMap-0x8000+MapWidth] = 1
+
+	var line [visibleWidth]uint8
+	mem.ioregs[RegWX-0xff00] = 7 + 20
+	assert.True(t, drawWindowLine(&line, mem, 8))
+	assert.Equal(t, uint8(0), line[19])
+	assert.Equal(t, uint8(3), line[20])
+	assert.Equal(t, uint8(3), line[27])
+	assert.Equal(t, uint8(0), line[28])
+
+	// window off screen to the right
+	mem.ioregs[RegWX-0xff00] = 167
+	assert.False(t, drawWindowLine(&line, mem, 8))
+
+	// window disabled
+	mem.ioregs[RegWX-0xff00] = 7
+	mem.ioregs[RegLCDC-0xff00] &^= LCDCWindowEnable
+	assert.False(t, drawWindowLine(&line, mem, 8))
 }
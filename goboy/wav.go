@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/binary"
+	"io"
+	"os"
+)
+
+const (
+	wavHeaderSize = 44
+	wavChannels   = 2
+	wavBits       = 16
+)
+
+/*
+ * WAVWriter records 16-bit stereo PCM. The RIFF and data chunk sizes
+ * aren't known until the end, so Close goes back and fills them in.
+ */
+type WAVWriter struct {
+	w     io.WriteSeeker
+	file  *os.File /* closed with the writer when set */
+	rate  int
+	bytes uint32 /* sample data written */
+	buf   []uint8
+}
+
+func newWAVWriter(w io.WriteSeeker, rate int) (*WAVWriter, error) {
+	wav := &WAVWriter{w: w, rate: rate}
+	if _, err := w.Write(wav.header()); err != nil {
+		return nil, err
+	}
+	return wav, nil
+}
+
+func createWAV(path string, rate int) (*WAVWriter, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	wav, err := newWAVWriter(f, rate)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	wav.file = f
+	return wav, nil
+}
+
+func (wav *WAVWriter) header() []uint8 {
+	h := make([]uint8, wavHeaderSize)
+	le := binary.LittleEndian
+	blockAlign := wavChannels * wavBits / 8
+	copy(h[0:], "RIFF")
+	le.PutUint32(h[4:], wavHeaderSize-8+wav.bytes)
+	copy(h[8:], "WAVE")
+	copy(h[12:], "fmt ")
+	le.PutUint32(h[16:], 16)
+	le.PutUint16(h[20:], 1) /* PCM */
+	le.PutUint16(h[22:], wavChannels)
+	le.PutUint32(h[24:], uint32(wav.rate))
+	le.PutUint32(h[28:], uint32(wav.rate*blockAlign))
+	le.PutUint16(h[32:], uint16(blockAlign))
+	le.PutUint16(h[34:], wavBits)
+	copy(h[36:], "data")
+	le.PutUint32(h[40:], wav.bytes)
+	return h
+}
+
+func (wav *WAVWriter) WriteSamples(samples []StereoSample) error {
+	wav.buf = wav.buf[:0]
+	for _, s := range samples {
+		wav.buf = append(wav.buf, uint8(s.Left), uint8(uint16(s.Left)>>8),
+			uint8(s.Right), uint8(uint16(s.Right)>>8))
+	}
+	n, err := wav.w.Write(wav.buf)
+	wav.bytes += uint32(n)
+	return err
+}
+
+// Close completes the header, then closes the file if createWAV opened it
+func (wav *WAVWriter) Close() error {
+	_, err := wav.w.Seek(0, io.SeekStart)
+	if err == nil {
+		_, err = wav.w.Write(wav.header())
+	}
+	if wav.file != nil {
+		if cerr := wav.file.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"encoding/binary"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWAVWriter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goboy")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "out.wav")
+
+	wav, err := createWAV(path, 22050)
+	assert.Nil(t, err)
+	assert.Nil(t, wav.WriteSamples([]StereoSample{{1, -1}, {0x1234, -0x1234}}))
+	assert.Nil(t, wav.WriteSamples([]StereoSample{{-32768, 32767}}))
+	assert.Nil(t, wav.Close())
+
+	data, err := ioutil.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Equal(t, wavHeaderSize+12, len(data))
+	le := binary.LittleEndian
+	assert.Equal(t, "RIFF", string(data[0:4]))
+	assert.Equal(t, uint32(len(data)-8), le.Uint32(data[4:]))
+	assert.Equal(t, "WAVEfmt ", string(data[8:16]))
+	assert.Equal(t, uint16(1), le.Uint16(data[20:]))
+	assert.Equal(t, uint16(2), le.Uint16(data[22:]))
+	assert.Equal(t, uint32(22050), le.Uint32(data[24:]))
+	assert.Equal(t, uint32(22050*4), le.Uint32(data[28:]))
+	assert.Equal(t, uint16(16), le.Uint16(data[34:]))
+	assert.Equal(t, "data", string(data[36:40]))
+	assert.Equal(t, uint32(12), le.Uint32(data[40:]))
+	samples := data[wavHeaderSize:]
+	assert.Equal(t, int16(1), int16(le.Uint16(samples[0:])))
+	assert.Equal(t, int16(-1), int16(le.Uint16(samples[2:])))
+	assert.Equal(t, int16(-0x1234), int16(le.Uint16(samples[6:])))
+	assert.Equal(t, int16(-32768), int16(le.Uint16(samples[8:])))
+	assert.Equal(t, int16(32767), int16(le.Uint16(samples[10:])))
+}